@@ -11,6 +11,7 @@ import (
 
 	"iaprj/internal/chat"
 	"iaprj/internal/config"
+	"iaprj/internal/embeddings"
 	"iaprj/internal/repository"
 )
 
@@ -39,9 +40,19 @@ func main() {
 
 	client := openai.NewClient(cfg.OpenAIKey)
 
+	// As buscas usam sempre o modelo do índice ativo (embedding_indexes)
+	embedder, err := embeddings.NewActiveIndexEmbedder(
+		cfg,
+		&repository.EmbeddingIndexRepository{DB: pool},
+		&repository.EmbeddingCacheRepository{DB: pool},
+	)
+	if err != nil {
+		log.Fatalf("Erro ao carregar o índice de embeddings ativo: %v", err)
+	}
+
 	http.Handle(
 		"/chat",
-		chat.Handler(vectorRepo, sessionStore, client),
+		chat.Handler(vectorRepo, sessionStore, client, embedder),
 	)
 
 	http.HandleFunc("/view", func(w http.ResponseWriter, r *http.Request) {
@@ -11,6 +11,7 @@ import (
 
 	"iaprj/internal/chat"
 	"iaprj/internal/config"
+	"iaprj/internal/embeddings"
 	"iaprj/internal/repository"
 )
 
@@ -28,6 +29,8 @@ func main() {
 	defer pool.Close()
 
 	vectorRepo := &repository.VectorRepository{DB: pool}
+	catalogRepo := &repository.CatalogRepository{DB: pool}
+	priceRepo := &repository.PriceHistoryRepository{DB: pool}
 
 	redisClient := redis.NewClient(&redis.Options{
 		Addr: cfg.RedisURL,
@@ -39,10 +42,20 @@ func main() {
 
 	client := openai.NewClient(cfg.OpenAIKey)
 
+	// As buscas usam sempre o modelo do índice ativo (embedding_indexes)
+	embedder, err := embeddings.NewActiveIndexEmbedder(
+		cfg,
+		&repository.EmbeddingIndexRepository{DB: pool},
+		&repository.EmbeddingCacheRepository{DB: pool},
+	)
+	if err != nil {
+		log.Fatalf("Erro ao carregar o índice de embeddings ativo: %v", err)
+	}
+
 	// Usa o HandlerV2 que implementa a lógica de busca por metadados primeiro
 	http.Handle(
 		"/chat",
-		chat.HandlerV2(vectorRepo, sessionStore, client),
+		chat.HandlerV2(vectorRepo, catalogRepo, priceRepo, sessionStore, client, embedder),
 	)
 
 	http.HandleFunc("/view", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+
+	"iaprj/internal/crawler"
+	"iaprj/internal/repository"
+)
+
+// dryRunDiff crawleia as origens e compara com o banco, sem gravar nada.
+func dryRunDiff(repo *repository.RawRepository, sources []crawler.CatalogSource, workers int) (crawler.DiffReport, error) {
+	existing, err := repo.ListActive()
+	if err != nil {
+		return crawler.DiffReport{}, err
+	}
+	diff := crawler.NewCatalogDiff(existing)
+
+	forEachSource(sources, workers, func(source crawler.CatalogSource) {
+		log.Printf("[dry-run] Buscando produtos em %s", source.Name())
+		if err := source.Crawl(diff.Add); err != nil {
+			log.Printf("[dry-run] Erro ao buscar produtos em %s: %v", source.Name(), err)
+			return
+		}
+		// Só um crawl completo da categoria permite apontar produtos removidos
+		if catSource, ok := source.(*crawler.OCCCategorySource); ok {
+			diff.CategoryCompleted(catSource.CategoryID)
+		}
+	})
+
+	return diff.Report(), nil
+}
+
+// printDiffReport escreve o relatório do dry-run como tabela ou JSON.
+func printDiffReport(w io.Writer, report crawler.DiffReport, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	if format != "table" {
+		return fmt.Errorf("formato de relatório desconhecido: %s", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MUDANÇA\tPRODUTO\tCAMPO\tANTES\tDEPOIS")
+	for _, p := range report.Added {
+		fmt.Fprintf(tw, "adicionado\t%s\t\t\t%s\n", p.ProdutoID, p.Name)
+	}
+	for _, p := range report.Removed {
+		fmt.Fprintf(tw, "removido\t%s\tcategoria\t%s\t\n", p.ProdutoID, p.Category)
+	}
+	for _, p := range report.Changed {
+		for _, f := range p.Fields {
+			fmt.Fprintf(tw, "alterado\t%s\t%s\t%s\t%s\n", p.ProdutoID, f.Field, f.Old, f.New)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Warnings) > 0 {
+		fmt.Fprintln(w, "\nAVISOS DE CADASTRO:")
+		for _, warn := range report.Warnings {
+			fmt.Fprintf(w, "  %s: %s\n", warn.ProdutoID, warn.Message)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d adicionados, %d removidos, %d alterados, %d sem alteração, %d avisos\n",
+		len(report.Added), len(report.Removed), len(report.Changed), report.Unchanged, len(report.Warnings))
+	return err
+}
@@ -4,53 +4,482 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 	"strings"
-
-	"github.com/google/uuid"
+	"sync"
+	"time"
 
 	"iaprj/internal/config"
 	"iaprj/internal/crawler"
 	"iaprj/internal/db"
 	"iaprj/internal/model"
+	"iaprj/internal/observability"
 	"iaprj/internal/repository"
 )
 
 // go run cmd/crawler/main.go -mode=ids -ids="kit123,kit456,kit789"
 // go run cmd/crawler/main.go -mode=category -cat="ar-condicionado"
+// go run cmd/crawler/main.go -mode=category -cat="ar-condicionado" -resume
+// go run cmd/crawler/main.go -mode=tree -cat="departamentos" -workers=4
+// go run cmd/crawler/main.go -source=occ -base-url="https://outra-loja.com.br" -cat="ar-condicionado"
+// go run cmd/crawler/main.go -source=file -file="catalogo.csv"
+// go run cmd/crawler/main.go -source=html
+// go run cmd/crawler/main.go -mode=ids -ids="kit123,kit456" -html-fallback
+// go run cmd/crawler/main.go -mode=tree -cat="departamentos" -out=catalog.ndjson
+// go run cmd/crawler/main.go -in=catalog.ndjson
+// go run cmd/crawler/main.go -mode=tree -cat="departamentos" -dry-run -report=json
+// go run cmd/crawler/main.go -mode=tree -cat="departamentos" -template-version=v2
 func main() {
-	mode := flag.String("mode", "category", "Modo de execução: 'ids' ou 'category'")
-	cat := flag.String("cat", "ar-condicionado", "ID da categoria para busca")
+	mode := flag.String("mode", "category", "Modo de execução: 'ids', 'category' ou 'tree' (categoria e todas as filhas)")
+	cat := flag.String("cat", "ar-condicionado", "ID da categoria para busca (raiz da árvore no modo tree)")
 	idsArg := flag.String("ids", "kit11106,kit9428,kit9429,kit10572", "IDs dos produtos separados por vírgula")
+	sourceName := flag.String("source", "frigelar", "Origem do catálogo: 'frigelar', 'occ' (usa -base-url), 'html' (páginas do sitemap) ou 'file' (usa -file)")
+	baseURL := flag.String("base-url", "", "URL base da loja OCC quando -source=occ")
+	filePath := flag.String("file", "", "Arquivo JSON/CSV quando -source=file")
+	resume := flag.Bool("resume", false, "Retoma o último crawl interrompido da categoria a partir do checkpoint")
+	purgeAfter := flag.Duration("purge-after", 30*24*time.Hour, "Tempo que um produto fica inativo antes de ser apagado (0 = não apaga)")
+	rps := flag.Float64("rps", crawler.DefaultRequestsPerSecond, "Máximo de requisições por segundo para a loja OCC (0 = sem limite)")
+	sitemap := flag.String("sitemap", "", "URL do sitemap para -source=html e -html-fallback (padrão: <loja>/sitemap.xml)")
+	htmlFallback := flag.Bool("html-fallback", false, "No modo ids, busca pelas páginas HTML os produtos que a API OCC não retornou")
+	workers := flag.Int("workers", 4, "Categorias crawleadas em paralelo no modo tree")
+	out := flag.String("out", "", "Grava os registros originais dos produtos crawleados neste arquivo NDJSON (metadados do crawl em <arquivo>.meta)")
+	in := flag.String("in", "", "Reproduz um snapshot NDJSON gravado com -out, sem acessar a loja")
+	dryRun := flag.Bool("dry-run", false, "Compara o crawl com product_raw_knowledge e imprime as diferenças, sem gravar nada")
+	reportFormat := flag.String("report", "table", "Formato do relatório do -dry-run: 'table' ou 'json'")
+	templateVersion := flag.String("template-version", crawler.DefaultTextTemplateVersion, "Versão do layout do texto de embedding (templates/<versão>/<categoria>.tmpl)")
+	templateDir := flag.String("template-dir", "", "Diretório com templates próprios no lugar dos embutidos")
 	flag.Parse()
 
+	if *reportFormat != "table" && *reportFormat != "json" {
+		log.Fatalf("Configuração inválida: -report deve ser 'table' ou 'json'")
+	}
+	if *workers < 1 {
+		log.Fatalf("Configuração inválida: -workers deve ser pelo menos 1")
+	}
+	// Só o modo ids sabe quais produtos a API deixou de retornar
+	if *htmlFallback && *mode != "ids" {
+		log.Fatalf("Configuração inválida: -html-fallback só é suportado com -mode=ids")
+	}
+	templates, err := crawler.LoadTextTemplates(*templateVersion, *templateDir)
+	if err != nil {
+		log.Fatalf("Erro ao carregar templates de texto: %v", err)
+	}
+	crawler.UseTextTemplates(templates)
+
+	opts := sourceOptions{
+		name:     *sourceName,
+		baseURL:  *baseURL,
+		filePath: *filePath,
+		mode:     *mode,
+		cat:      *cat,
+		ids:      *idsArg,
+		rps:      *rps,
+		sitemap:  *sitemap,
+		fallback: *htmlFallback,
+	}
+	var sources []crawler.CatalogSource
+	var client *crawler.OCCClient
+	if *in != "" {
+		// Snapshot offline: nenhuma requisição para a loja
+		sources = append(sources, &crawler.SnapshotSource{Path: *in})
+	} else if client, err = buildClient(opts); err != nil {
+		log.Fatalf("Configuração inválida: %v", err)
+	} else if *mode == "tree" {
+		if client == nil {
+			log.Fatalf("Configuração inválida: o modo tree exige uma origem OCC")
+		}
+		root, err := client.FetchCategoryTree(*cat)
+		if err != nil {
+			log.Fatalf("Erro ao buscar árvore de categorias de %s: %v", *cat, err)
+		}
+		for _, node := range root.Flatten() {
+			sources = append(sources, &crawler.OCCCategorySource{
+				Client:          client,
+				CategoryID:      node.ID,
+				CategoryPath:    node.Path,
+				ExcludeChildren: true,
+			})
+		}
+		log.Printf("Árvore de %s: %d categorias encontradas", *cat, len(sources))
+	} else {
+		source, err := buildSource(client, opts)
+		if err != nil {
+			log.Fatalf("Configuração inválida: %v", err)
+		}
+		sources = append(sources, source)
+	}
+
 	cfg := config.Load()
 	dbConn, _ := db.New(cfg.DatabaseURL)
 
-	repo := &repository.RawRepository{DB: dbConn}
+	if *dryRun {
+		report, err := dryRunDiff(&repository.RawRepository{DB: dbConn}, sources, *workers)
+		if err != nil {
+			log.Fatalf("Erro no dry-run: %v", err)
+		}
+		if err := printDiffReport(os.Stdout, report, *reportFormat); err != nil {
+			log.Fatalf("Erro ao imprimir relatório: %v", err)
+		}
+		return
+	}
+
+	if err := db.Migrate(dbConn); err != nil {
+		log.Fatalf("Erro ao aplicar migrations: %v", err)
+	}
+
+	observability.Start(cfg.MetricsPort)
+
+	job := &crawlJob{
+		repo:   &repository.RawRepository{DB: dbConn},
+		runs:   &repository.CrawlRunRepository{DB: dbConn},
+		resume: *resume,
+	}
+	if *out != "" {
+		job.snapshot, err = crawler.NewSnapshotWriter(*out)
+		if err != nil {
+			log.Fatalf("Erro ao criar snapshot %s: %v", *out, err)
+		}
+	}
+
+	// Cada categoria é uma execução independente em crawl_runs
+	results := make(chan *model.CrawlRun, len(sources))
+	forEachSource(sources, *workers, func(source crawler.CatalogSource) {
+		if run := job.crawl(source); run != nil {
+			results <- run
+		}
+	})
+	close(results)
+
+	if job.snapshot != nil {
+		if err := job.snapshot.Close(); err != nil {
+			log.Printf("Erro ao gravar snapshot %s: %v", *out, err)
+		} else {
+			log.Printf("Snapshot gravado em %s", *out)
+		}
+	}
+
+	if *purgeAfter > 0 {
+		purged, err := job.repo.PurgeInactive(*purgeAfter)
+		if err != nil {
+			log.Printf("Erro ao apagar produtos inativos: %v", err)
+		} else if purged > 0 {
+			log.Printf("%d produtos inativos há mais de %s foram apagados", purged, *purgeAfter)
+		}
+	}
+
+	total := &runSummary{run: &model.CrawlRun{}}
+	for run := range results {
+		total.add(run)
+	}
+	log.Printf("Crawler finalizado: %s", total)
+
+	observability.Push(cfg.PushgatewayURL, "crawler")
+}
+
+// forEachSource executa fn para cada origem com um pool limitado de workers.
+func forEachSource(sources []crawler.CatalogSource, workers int, fn func(crawler.CatalogSource)) {
+	sourcesCh := make(chan crawler.CatalogSource)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(sources); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for source := range sourcesCh {
+				fn(source)
+			}
+		}()
+	}
+	for _, source := range sources {
+		sourcesCh <- source
+	}
+	close(sourcesCh)
+	wg.Wait()
+}
+
+// crawlJob executa uma CatalogSource como uma execução registrada em crawl_runs:
+// retoma do checkpoint, salva os produtos e inativa os que sumiram da categoria.
+type crawlJob struct {
+	repo     *repository.RawRepository
+	runs     *repository.CrawlRunRepository
+	resume   bool
+	snapshot *crawler.SnapshotWriter
+}
+
+func (j *crawlJob) crawl(source crawler.CatalogSource) *model.CrawlRun {
+	catSource, isCategory := source.(*crawler.OCCCategorySource)
+	category := ""
+	if isCategory {
+		category = catSource.CategoryID
+	}
+
+	var run *model.CrawlRun
+	var err error
+	if j.resume && isCategory {
+		run, err = j.runs.FindResumable(source.Name(), category)
+		if err != nil {
+			log.Printf("Erro ao buscar crawl para retomar da categoria %s: %v", category, err)
+			return nil
+		}
+		if run != nil {
+			log.Printf("Retomando crawl #%d de %s a partir de %s", run.ID, run.StartedAt.Format(time.RFC3339), run.NextURL)
+			catSource.StartURL = run.NextURL
+		} else {
+			log.Printf("Nenhum crawl interrompido para a categoria %s, iniciando do começo", category)
+		}
+	}
+	if run == nil {
+		run, err = j.runs.Start(source.Name(), category)
+		if err != nil {
+			log.Printf("Erro ao registrar execução do crawler: %v", err)
+			return nil
+		}
+	}
+
+	_, isSnapshot := source.(*crawler.SnapshotSource)
+	summary := &runSummary{run: run}
+	if isCategory {
+		catSource.OnPage = func(nextURL string) {
+			run.NextURL = nextURL
+			if err := j.runs.Checkpoint(run); err != nil {
+				log.Printf("Erro ao salvar checkpoint do crawl #%d: %v", run.ID, err)
+			}
+		}
+	}
+
+	handler := func(p crawler.Product) {
+		if j.snapshot != nil {
+			if err := j.snapshot.Write(p); err != nil {
+				log.Printf("Erro ao gravar produto %s no snapshot: %v", p.ID, err)
+			}
+		}
+
+		// Produto listado em várias categorias mantém a categoria principal enquanto
+		// continuar nela; a categoria deste crawl é registrada como mais uma
+		listedPath := p.CategoryPath
+		primaryID := category
+		if category != "" {
+			id, path, err := j.repo.PrimaryCategory(p.ID, category, p.CategoryPath)
+			if err != nil {
+				log.Printf("Erro ao buscar categoria principal do produto %s: %v", p.ID, err)
+			} else {
+				primaryID, p.CategoryPath = id, path
+			}
+		}
 
-	handler := func(p crawler.OCCProduct) {
-		text := crawler.ProductToText(&p)
 		// salvar no postgres
-		fmt.Println(text)
-		repo.Save(model.RawProduct{
-			ID:        uuid.New().String(),
-			ProdutoID: p.ID,
-			SourceURL: p.Url,
-			Content:   text,
-		})
+		raw := crawler.ToRawProduct(p)
+		raw.CategoryID = primaryID
+		raw.ListedCategoryID = category
+		raw.ListedCategoryPath = listedPath
+		status, err := j.repo.Save(raw)
+		if err != nil {
+			log.Printf("Erro ao salvar produto %s: %v", p.ID, err)
+			summary.fail()
+			return
+		}
+		summary.record(status)
+
+		// O preço de um snapshot não é uma observação nova
+		if !isSnapshot {
+			if err := j.repo.RecordPrice(raw); err != nil {
+				log.Printf("Erro ao registrar histórico de preço do produto %s: %v", p.ID, err)
+			}
+		}
+
+		if err := j.repo.SaveVariants(p.ID, toVariants(p)); err != nil {
+			log.Printf("Erro ao salvar variantes do produto %s: %v", p.ID, err)
+		}
+
+		// Só a API OCC conhece a composição dos kits
+		if p.Source == "occ" && p.ComponentsResolved() {
+			if err := j.repo.SaveComponents(p.ID, toComponents(p)); err != nil {
+				log.Printf("Erro ao salvar componentes do kit %s: %v", p.ID, err)
+			}
+		}
 	}
 
-	if *mode == "ids" {
-		ids := strings.Split(*idsArg, ",")
-		for i := range ids {
-			ids[i] = strings.TrimSpace(ids[i])
+	if fileSource, ok := source.(*crawler.FileSource); ok {
+		fileSource.OnInvalid = func(line int, err error) {
+			log.Printf("Linha %d de %s ignorada: %v", line, fileSource.Path, err)
+			summary.fail()
 		}
-		crawler.CrawlBatch(ids, 10, handler)
-	} else {
-		if err := crawler.FetchProductsByCategory(*cat, handler); err != nil {
-			log.Printf("Erro ao buscar categoria %s: %v", *cat, err)
+	}
+
+	log.Printf("Iniciando crawler #%d com a origem %s %s", run.ID, source.Name(), category)
+	crawlErr := source.Crawl(handler)
+	if crawlErr != nil {
+		log.Printf("Erro ao buscar produtos em %s %s: %v", source.Name(), category, crawlErr)
+	}
+	if err := j.runs.Finish(run, crawlErr); err != nil {
+		log.Printf("Erro ao finalizar execução #%d: %v", run.ID, err)
+	}
+
+	// Só um crawl completo da categoria permite concluir que um produto saiu do
+	// catálogo. Um produto que falhou ao ser salvo foi listado, mas mantém o
+	// crawled_at antigo e seria inativado junto com os ausentes.
+	if isCategory && crawlErr == nil && run.Failed == 0 && run.New+run.Changed+run.Unchanged > 0 {
+		deactivated, err := j.repo.DeactivateMissing(category, run.StartedAt)
+		if err != nil {
+			log.Printf("Erro ao inativar produtos ausentes da categoria %s: %v", category, err)
+		} else {
+			log.Printf("%d produtos não encontrados na categoria %s foram inativados", deactivated, category)
+		}
+	} else if isCategory && run.Failed > 0 {
+		log.Printf("%d produtos da categoria %s falharam; inativação dos ausentes ignorada neste crawl", run.Failed, category)
+	}
+
+	log.Printf("Crawl #%d finalizado: %s", run.ID, summary)
+	return run
+}
+
+// sourceOptions reúne as flags que definem de onde o catálogo é lido.
+type sourceOptions struct {
+	name     string
+	baseURL  string
+	filePath string
+	mode     string
+	cat      string
+	ids      string
+	rps      float64
+	sitemap  string
+	fallback bool
+}
+
+// storeURL é a loja usada pelas origens OCC e HTML.
+func (o sourceOptions) storeURL() string {
+	if o.baseURL != "" {
+		return strings.TrimRight(o.baseURL, "/")
+	}
+	return crawler.FrigelarBaseURL
+}
+
+// buildClient cria o cliente OCC da origem escolhida; devolve nil para origens que não são OCC.
+func buildClient(opts sourceOptions) (*crawler.OCCClient, error) {
+	var client *crawler.OCCClient
+	switch opts.name {
+	case "frigelar":
+		client = crawler.NewOCCClient(crawler.FrigelarBaseURL)
+	case "occ":
+		if opts.baseURL == "" {
+			return nil, fmt.Errorf("-base-url é obrigatório para -source=occ")
+		}
+		client = crawler.NewOCCClient(opts.baseURL)
+	case "file", "html":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("origem desconhecida: %s", opts.name)
+	}
+	client.HTTP = crawler.NewHTTPClient(opts.rps)
+	return client, nil
+}
+
+// buildSource monta a CatalogSource a partir das flags de linha de comando.
+func buildSource(client *crawler.OCCClient, opts sourceOptions) (crawler.CatalogSource, error) {
+	htmlSource := &crawler.HTMLSource{
+		BaseURL:    opts.storeURL(),
+		SitemapURL: opts.sitemap,
+		HTTP:       crawler.NewHTTPClient(opts.rps),
+	}
+
+	switch opts.name {
+	case "file":
+		if opts.filePath == "" {
+			return nil, fmt.Errorf("-file é obrigatório para -source=file")
+		}
+		return &crawler.FileSource{Path: opts.filePath}, nil
+	case "html":
+		if opts.mode == "ids" {
+			htmlSource.IDs = splitIDs(opts.ids)
+		}
+		return htmlSource, nil
+	}
+
+	if opts.mode == "ids" {
+		source := &crawler.OCCIDsSource{Client: client, IDs: splitIDs(opts.ids), BatchSize: 10}
+		if opts.fallback {
+			return &crawler.IDsWithHTMLFallback{Primary: source, Fallback: htmlSource}, nil
 		}
+		return source, nil
+	}
+	return &crawler.OCCCategorySource{Client: client, CategoryID: opts.cat}, nil
+}
+
+func splitIDs(arg string) []string {
+	ids := strings.Split(arg, ",")
+	for i := range ids {
+		ids[i] = strings.TrimSpace(ids[i])
+	}
+	return ids
+}
+
+// toVariants converte as variantes de x_variants para o modelo da tabela product_variants.
+func toVariants(p crawler.Product) []model.ProductVariant {
+	var variants []model.ProductVariant
+	for _, v := range crawler.ParseVariants(p.ID, p.Variants) {
+		variants = append(variants, model.ProductVariant{
+			ProdutoID:        p.ID,
+			VariantProdutoID: v.ProductID,
+			Label:            v.Label,
+			Attribute:        v.Attribute,
+		})
 	}
+	return variants
+}
+
+// toComponents converte os componentes do kit para o modelo da tabela product_components.
+func toComponents(p crawler.Product) []model.ProductComponent {
+	var components []model.ProductComponent
+	for _, c := range p.Components {
+		components = append(components, model.ProductComponent{
+			ProdutoID:          p.ID,
+			ComponentSKU:       c.SKU,
+			ComponentProdutoID: c.ProductID,
+			Name:               c.Name,
+			Role:               c.Role,
+			Quantity:           c.Quantity,
+			Length:             c.Length,
+			Weight:             c.Weight,
+			Width:              c.Width,
+			Height:             c.Height,
+			SalePrice:          c.SalePrice,
+		})
+	}
+	return components
+}
+
+// runSummary contabiliza, na execução registrada em crawl_runs, o resultado
+// de cada produto salvo.
+type runSummary struct {
+	run *model.CrawlRun
+}
+
+func (s *runSummary) record(status repository.SaveStatus) {
+	switch status {
+	case repository.SaveNew:
+		s.run.New++
+		observability.CrawlerProductsTotal.WithLabelValues(s.run.Source, "new").Inc()
+	case repository.SaveChanged:
+		s.run.Changed++
+		observability.CrawlerProductsTotal.WithLabelValues(s.run.Source, "changed").Inc()
+	case repository.SaveUnchanged:
+		s.run.Unchanged++
+		observability.CrawlerProductsTotal.WithLabelValues(s.run.Source, "unchanged").Inc()
+	}
+}
+
+func (s *runSummary) fail() {
+	s.run.Failed++
+	observability.CrawlerProductsTotal.WithLabelValues(s.run.Source, "failed").Inc()
+}
+
+// add soma os contadores de outra execução (total do modo tree).
+func (s *runSummary) add(run *model.CrawlRun) {
+	s.run.New += run.New
+	s.run.Changed += run.Changed
+	s.run.Unchanged += run.Unchanged
+	s.run.Failed += run.Failed
+}
 
-	log.Println("Crawler finalizado")
+func (s *runSummary) String() string {
+	return fmt.Sprintf("%d novos, %d alterados, %d sem alteração, %d com falha", s.run.New, s.run.Changed, s.run.Unchanged, s.run.Failed)
 }
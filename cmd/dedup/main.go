@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"iaprj/internal/config"
+	"iaprj/internal/db"
+	"iaprj/internal/dedup"
+	"iaprj/internal/repository"
+)
+
+// Agrupa anúncios do mesmo modelo (kit x avulso, marketplace, SKUs recadastrados)
+// em product_groups para que a busca mostre um representativo por grupo.
+//
+// go run cmd/dedup/main.go
+// go run cmd/dedup/main.go -similarity=0.98 -dry-run
+func main() {
+	similarity := flag.Float64("similarity", dedup.DefaultSimilarity, "Similaridade mínima dos embeddings para agrupar produtos do mesmo bloco")
+	dryRun := flag.Bool("dry-run", false, "Só imprime os grupos encontrados, sem gravar")
+	flag.Parse()
+
+	cfg := config.Load()
+
+	dbConn, err := db.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Erro ao conectar no banco de dados (db): %v", err)
+	}
+	if err := db.Migrate(dbConn); err != nil {
+		log.Fatalf("Erro ao aplicar migrations: %v", err)
+	}
+
+	pool, err := pgxpool.New(context.Background(), cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Não foi possível criar o pool de conexões: %v\n", err)
+	}
+	defer pool.Close()
+
+	repo := &repository.GroupRepository{DB: pool}
+	candidates, err := repo.ListCandidates()
+	if err != nil {
+		log.Fatalf("Erro ao listar produtos: %v", err)
+	}
+	log.Printf("Comparando %d produtos (similaridade mínima %.2f)...", len(candidates), *similarity)
+
+	members := dedup.Cluster(candidates, *similarity)
+
+	groups := 0
+	for _, m := range members {
+		if m.Canonical {
+			groups++
+		}
+		if *dryRun {
+			log.Printf("[grupo %s] %s canônico=%t motivo=%s", m.GroupID, m.ProdutoID, m.Canonical, m.Reason)
+		}
+	}
+	log.Printf("%d grupos com %d produtos duplicados encontrados", groups, len(members)-groups)
+
+	if *dryRun {
+		return
+	}
+	if err := repo.ReplaceGroups(members); err != nil {
+		log.Fatalf("Erro ao gravar grupos: %v", err)
+	}
+	log.Println("Dedup finalizado")
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
-	"html"
+	"errors"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
 	"regexp"
-	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"iaprj/internal/config"
 	"iaprj/internal/db"
@@ -18,15 +22,43 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// Gera os embeddings dos produtos pendentes no índice ativo e, se houver um
+// índice em construção, também na tabela sombra.
+//
+// go run cmd/embeddings/main.go
+// go run cmd/embeddings/main.go -build-index -model=text-embedding-3-large -dimensions=1024
+// go run cmd/embeddings/main.go -switch-index
+// go run cmd/embeddings/main.go -retry-failed -max-attempts=5
+// go run cmd/embeddings/main.go -reset-failed=<produto_id|all>
 func main() {
+	buildIndex := flag.Bool("build-index", false, "Cria um índice sombra com -model/-dimensions e gera os embeddings de todo o catálogo nele")
+	switchIndex := flag.Bool("switch-index", false, "Promove o índice sombra a índice ativo")
+	force := flag.Bool("force", false, "Com -switch-index, troca mesmo que a sombra não cubra todos os produtos ativos")
+	modelName := flag.String("model", "", "Modelo do novo índice (padrão: EMBEDDING_MODEL)")
+	dimensions := flag.Int("dimensions", 0, "Dimensão do novo índice (padrão: EMBEDDING_DIMENSIONS)")
+	retryFailed := flag.Bool("retry-failed", false, "Reprocessa apenas os produtos da fila de falhas (embedding_failures)")
+	resetFailed := flag.String("reset-failed", "", "Zera as tentativas de um produto da fila de falhas (ou de todos, com \"all\") e sai")
+	maxAttempts := flag.Int("max-attempts", 0, "Tentativas antes de o produto exigir inspeção manual (padrão: EMBEDDING_MAX_ATTEMPTS)")
+	flag.Parse()
+
 	cfg := config.Load()
+	if *maxAttempts <= 0 {
+		*maxAttempts = cfg.EmbeddingMaxAttempts
+	}
 
 	observability.Start(cfg.MetricsPort)
 
+	// SIGINT/SIGTERM: termina os lotes em andamento e deixa o resto pendente
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	dbConn, err := db.New(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Erro ao conectar no banco de dados (db): %v", err)
 	}
+	if err := db.Migrate(dbConn); err != nil {
+		log.Fatalf("Erro ao aplicar migrations: %v", err)
+	}
 
 	pool, err := pgxpool.New(context.Background(), cfg.DatabaseURL)
 	if err != nil {
@@ -35,126 +67,177 @@ func main() {
 	defer pool.Close()
 
 	rawRepo := &repository.RawRepository{DB: dbConn}
-	vectorRepo := &repository.VectorRepository{DB: pool}
+	indexRepo := &repository.EmbeddingIndexRepository{DB: pool}
+	cacheRepo := &repository.EmbeddingCacheRepository{DB: pool}
+	// Um único orçamento para todos os workers e índices desta execução
+	limiter := embeddings.NewRateLimiter(cfg.EmbeddingTPM, cfg.EmbeddingRPM)
 
-	products, err := rawRepo.List()
-	if err != nil {
-		log.Fatalf("Erro ao listar produtos: %v", err)
+	if *resetFailed != "" {
+		id := *resetFailed
+		if id == "all" {
+			id = ""
+		}
+		n, err := rawRepo.ResetEmbeddingFailures(id)
+		if err != nil {
+			log.Fatalf("Erro ao limpar a fila de falhas: %v", err)
+		}
+		log.Printf("%d produtos removidos da fila de falhas; voltam a ser processados na próxima execução", n)
+		return
 	}
 
-	// Etapa de Limpeza e Enriquecimento dos dados antes de gerar embeddings
-	log.Println("Iniciando limpeza dos dados brutos...")
-	for i := range products {
-		cleanProductData(&products[i])
-		// Log de auditoria para verificar se a extração está funcionando
-		if i < 10 || products[i].Brand == "EOS" { // Loga os primeiros 10 e todos os EOS encontrados
-			log.Printf("[AUDIT] ID: %s | Marca: '%s' | BTUs: %d | Ciclo: '%s' | Volt: '%s' | Tech: '%s' | Tipo: '%s'", products[i].ProdutoID, products[i].Brand, products[i].Btus, products[i].Ciclo, products[i].Voltagem, products[i].Tecnologia, products[i].Type)
+	if *switchIndex {
+		idx, err := indexRepo.SwitchOver(*force)
+		var incomplete *repository.IncompleteShadowError
+		if errors.As(err, &incomplete) {
+			for _, id := range incomplete.Missing {
+				log.Printf("[Índice] Sem vetores atualizados na sombra: %s", id)
+			}
+			log.Fatalf("Troca cancelada: %v. Rode os embeddings pendentes, gere o índice novamente com -build-index ou use -force", err)
 		}
+		if err != nil {
+			log.Fatalf("Erro ao trocar o índice de embeddings: %v", err)
+		}
+		log.Printf("Índice ativo agora é %s (%d dimensões)", idx.Model, idx.Dimensions)
+		return
 	}
 
-	embeddings.RunWorkers(products, vectorRepo, rawRepo, cfg.WorkerCount)
+	if *buildIndex {
+		if *modelName == "" {
+			*modelName = cfg.EmbeddingModel
+		}
+		if *dimensions == 0 {
+			*dimensions = cfg.EmbeddingDimensions
+		}
+		if err := indexRepo.CreateShadow(*modelName, *dimensions); err != nil {
+			log.Fatalf("Erro ao criar índice sombra: %v", err)
+		}
 
-	log.Println("Embeddings finalizadas")
-}
+		products, err := rawRepo.ListIndexable()
+		if err != nil {
+			log.Fatalf("Erro ao listar produtos: %v", err)
+		}
+		log.Printf("Construindo índice %s (%d dimensões) com %d produtos", *modelName, *dimensions, len(products))
 
-// cleanProductData remove ruídos do conteúdo e ajusta a URL da imagem
-func cleanProductData(p *model.RawProduct) {
-	// 1. Decodificar HTML entities (ex: &nbsp; -> espaço, &aacute; -> á)
-	content := html.UnescapeString(p.Content)
+		shadowRepo := &repository.VectorRepository{DB: pool, Table: repository.ShadowKnowledgeTable}
+		embed(ctx, cfg, limiter, cacheRepo, *modelName, *dimensions, cleanProducts(products), shadowRepo, nil)
+		if ctx.Err() != nil {
+			log.Fatalf("Construção do índice interrompida; rode -build-index novamente")
+		}
 
-	// 2. Extrair URLs (Source e Imagem) se estiverem no conteúdo
-	// Usa (?i) para case-insensitive e \s* para pegar quebras de linha entre o label e o link
-	reUrl := regexp.MustCompile(`(?i)URL:\s*(https?://\S+)`)
-	if match := reUrl.FindStringSubmatch(content); len(match) > 1 {
-		p.SourceURL = match[1]
+		log.Println("Índice sombra concluído; use -switch-index para ativá-lo")
+		observability.Push(cfg.PushgatewayURL, "embeddings")
+		return
 	}
 
-	// Procura por "Imagem Principal: http..."
-	reImg := regexp.MustCompile(`(?i)Imagem Principal:\s*(https?://\S+)`)
-	if match := reImg.FindStringSubmatch(content); len(match) > 1 {
-		p.ImageURL = match[1]
+	var products []model.RawProduct
+	if *retryFailed {
+		products, err = rawRepo.ListFailed(*maxAttempts)
+		log.Printf("Reprocessando %d produtos da fila de falhas", len(products))
+	} else {
+		products, err = rawRepo.List(*maxAttempts)
 	}
-
-	// Extrair Marca (ex: "Marca: LG")
-	reBrand := regexp.MustCompile(`(?i)Marca:\s*(.+)`)
-	if match := reBrand.FindStringSubmatch(content); len(match) > 1 {
-		p.Brand = strings.TrimSpace(match[1])
+	if err != nil {
+		log.Fatalf("Erro ao listar produtos: %v", err)
 	}
+	products = cleanProducts(products)
 
-	// Extrair BTUs (ex: "Capacidade: 9000 BTUs")
-	reBtu := regexp.MustCompile(`(?i)Capacidade:\s*([\d\.]+)`)
-	if match := reBtu.FindStringSubmatch(content); len(match) > 1 {
-		valStr := strings.ReplaceAll(match[1], ".", "")
-		val, _ := strconv.Atoi(valStr)
-		p.Btus = val
+	active, err := indexRepo.Active()
+	if err != nil {
+		log.Fatalf("Erro ao consultar o índice ativo: %v", err)
 	}
-
-	// Extrair Ciclo (ex: "Ciclo: Quente/Frio")
-	reCiclo := regexp.MustCompile(`(?i)Ciclo:\s*(.+)`)
-	if match := reCiclo.FindStringSubmatch(content); len(match) > 1 {
-		p.Ciclo = strings.TrimSpace(match[1])
+	building, hasShadow, err := indexRepo.Building()
+	if err != nil {
+		log.Fatalf("Erro ao consultar o índice em construção: %v", err)
 	}
 
-	// Extrair Voltagem (ex: "Voltagem: 220V")
-	reVolt := regexp.MustCompile(`(?i)Voltagem:\s*(.+)`)
-	if match := reVolt.FindStringSubmatch(content); len(match) > 1 {
-		p.Voltagem = strings.TrimSpace(match[1])
+	// O índice sombra recebe primeiro: o produto só sai da fila quando entra no ativo
+	var shadowFailures []embeddings.Failure
+	if hasShadow {
+		shadowRepo := &repository.VectorRepository{DB: pool, Table: repository.ShadowKnowledgeTable}
+		shadowFailures = embed(ctx, cfg, limiter, cacheRepo, building.Model, building.Dimensions, products, shadowRepo, nil)
 	}
+	vectorRepo := &repository.VectorRepository{DB: pool}
+	embed(ctx, cfg, limiter, cacheRepo, active.Model, active.Dimensions, products, vectorRepo, rawRepo)
 
-	// Extrair Tecnologia (ex: "Tecnologia: Inverter")
-	reTech := regexp.MustCompile(`(?i)Tecnologia:\s*(.+)`)
-	if match := reTech.FindStringSubmatch(content); len(match) > 1 {
-		p.Tecnologia = strings.TrimSpace(match[1])
+	// Quem falhou só na sombra continuaria com vetores antigos nela e seria
+	// promovido assim no -switch-index; volta para a fila até entrar nos dois
+	for _, f := range shadowFailures {
+		if err := rawRepo.RequeueEmbedding(f.ProdutoID, "shadow_"+f.Reason, f.Err.Error()); err != nil {
+			log.Printf("Erro ao devolver %s para a fila de embeddings: %v", f.ProdutoID, err)
+		}
 	}
 
-	// Extrair Tipo (ex: "Categoria: Ar-Condicionado Split")
-	reType := regexp.MustCompile(`(?i)Categoria:\s*Ar-Condicionado\s*(.+)`)
-	if match := reType.FindStringSubmatch(content); len(match) > 1 {
-		p.Type = strings.TrimSpace(match[1])
+	exhausted, err := rawRepo.ListExhausted(*maxAttempts)
+	if err != nil {
+		log.Printf("Erro ao listar falhas de embedding: %v", err)
 	}
-
-	// Extrair Preço de Venda (ex: "Preço de Venda: R$ 2.499,00")
-	rePrice := regexp.MustCompile(`(?i)Preço de Venda:\s*(?:R\$\s*)?([\d\.,]+)`)
-	if match := rePrice.FindStringSubmatch(content); len(match) > 1 {
-		valStr := strings.TrimSpace(match[1])
-		valStr = strings.ReplaceAll(valStr, ",", ".")
-		if val, err := strconv.ParseFloat(valStr, 32); err == nil {
-			p.SalePrice = float32(val)
-		}
+	for _, f := range exhausted {
+		log.Printf("[DLQ] %s falhou %d vezes (última em %s, %s): %s", f.ProdutoID, f.Attempts, f.LastAttemptAt.Format(time.RFC3339), f.Reason, f.Error)
 	}
-
-	// Extrair Dimensões e Peso
-	reLength := regexp.MustCompile(`(?i)Comprimento:\s*(.+)`)
-	if match := reLength.FindStringSubmatch(content); len(match) > 1 {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(match[1]), 32); err == nil {
-			p.Length = float32(val)
-		}
+	if len(exhausted) > 0 {
+		log.Printf("%d produtos atingiram %d tentativas e precisam de inspeção manual (depois use -reset-failed)", len(exhausted), *maxAttempts)
 	}
-	reWidth := regexp.MustCompile(`(?i)Largura:\s*(.+)`)
-	if match := reWidth.FindStringSubmatch(content); len(match) > 1 {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(match[1]), 32); err == nil {
-			p.Width = float32(val)
-		}
+
+	log.Println("Embeddings finalizadas")
+	observability.Push(cfg.PushgatewayURL, "embeddings")
+}
+
+// embed gera os embeddings dos produtos com o modelo informado e grava em
+// vectorRepo, retornando os produtos que falharam.
+func embed(
+	ctx context.Context,
+	cfg *config.Config,
+	limiter *embeddings.RateLimiter,
+	cacheRepo *repository.EmbeddingCacheRepository,
+	modelName string,
+	dimensions int,
+	products []model.RawProduct,
+	vectorRepo *repository.VectorRepository,
+	rawRepo *repository.RawRepository,
+) []embeddings.Failure {
+	embedder, err := embeddings.NewEmbedderFor(cfg, modelName, dimensions)
+	if err != nil {
+		log.Fatalf("Configuração de embeddings inválida: %v", err)
 	}
-	reHeight := regexp.MustCompile(`(?i)Altura:\s*(.+)`)
-	if match := reHeight.FindStringSubmatch(content); len(match) > 1 {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(match[1]), 32); err == nil {
-			p.Height = float32(val)
-		}
+	tokenizer, err := embeddings.NewTokenizer(modelName)
+	if err != nil {
+		log.Fatalf("Erro ao carregar tokenizer: %v", err)
 	}
-	reWeight := regexp.MustCompile(`(?i)Peso:\s*(.+)`)
-	if match := reWeight.FindStringSubmatch(content); len(match) > 1 {
-		if val, err := strconv.ParseFloat(strings.TrimSpace(match[1]), 32); err == nil {
-			p.Weight = float32(val)
+	// O cache fica por fora para que só os textos novos consumam o orçamento da API
+	embedder = embeddings.NewRateLimitedEmbedder(embedder, limiter, tokenizer, cfg.EmbeddingMaxRetries)
+	embedder = embeddings.NewCachedEmbedder(embedder, cacheRepo)
+	chunker := embeddings.NewChunker(tokenizer, cfg.ChunkMaxTokens, cfg.ChunkOverlapTokens)
+
+	log.Printf("Gerando embeddings com o modelo %s (%d workers)", embedder.Model(), cfg.WorkerCount)
+	return embeddings.RunWorkers(ctx, products, chunker, embedder, vectorRepo, rawRepo, cfg.WorkerCount)
+}
+
+// cleanProducts aplica cleanProductData em todos os produtos antes de gerar os embeddings.
+func cleanProducts(products []model.RawProduct) []model.RawProduct {
+	// Etapa de Limpeza e Enriquecimento dos dados antes de gerar embeddings
+	log.Println("Iniciando limpeza dos dados brutos...")
+	for i := range products {
+		cleanProductData(&products[i])
+		// Log de auditoria para conferir as colunas tipadas vindas do crawler
+		if i < 10 || products[i].Brand == "EOS" { // Loga os primeiros 10 e todos os EOS encontrados
+			log.Printf("[AUDIT] ID: %s | Marca: '%s' | BTUs: %d | Ciclo: '%s' | Volt: '%s' | Tech: '%s' | Tipo: '%s'", products[i].ProdutoID, products[i].Brand, products[i].Btus, products[i].Ciclo, products[i].Voltagem, products[i].Tecnologia, products[i].Type)
 		}
 	}
+	return products
+}
+
+// cleanProductData remove ruídos do conteúdo antes de gerar os embeddings.
+// Marca, BTUs, preço e dimensões já chegam nas colunas tipadas de product_raw_knowledge.
+func cleanProductData(p *model.RawProduct) {
+	// As entidades HTML já foram decodificadas por crawler.HTMLToText; decodificar
+	// de novo transformaria um "&lt;" literal da descrição em "<"
 
-	// 3. Remover o bloco de JSON de variantes (gera muito ruído no embedding)
+	// 1. Remover o bloco de JSON de variantes (gera muito ruído no embedding)
 	// Remove "Variants: { ... }"
 	reVariants := regexp.MustCompile(`(?s)Variants:\s*\{.*?\}`)
-	content = reVariants.ReplaceAllString(content, "")
+	content := reVariants.ReplaceAllString(p.Content, "")
 
-	// 4. Limpar linhas desnecessárias
+	// 2. Limpar linhas desnecessárias
 	lines := strings.Split(content, "\n")
 	var cleanLines []string
 	for _, line := range lines {
@@ -167,7 +250,7 @@ func cleanProductData(p *model.RawProduct) {
 			strings.HasPrefix(trimmed, "Categoria Extra:") {
 			continue
 		}
-		// Remove também a URL crua se ela ficou solta numa linha (já extraímos para o campo estruturado)
+		// Remove também a URL crua se ela ficou solta numa linha (já está no campo estruturado)
 		if trimmed == p.SourceURL || trimmed == p.ImageURL {
 			continue
 		}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"iaprj/internal/config"
+	"iaprj/internal/crawler"
+	"iaprj/internal/db"
+	"iaprj/internal/model"
+	"iaprj/internal/repository"
+)
+
+// Reconstrói texto e colunas tipadas de product_raw_knowledge a partir do JSON
+// original guardado pelo crawler, sem acessar a loja. Produtos cujo texto mudou
+// voltam para a fila de embeddings.
+//
+// go run cmd/reprocess/main.go
+// go run cmd/reprocess/main.go -template-version=v2
+func main() {
+	templateVersion := flag.String("template-version", crawler.DefaultTextTemplateVersion, "Versão do layout do texto de embedding (templates/<versão>/<categoria>.tmpl)")
+	templateDir := flag.String("template-dir", "", "Diretório com templates próprios no lugar dos embutidos")
+	flag.Parse()
+
+	templates, err := crawler.LoadTextTemplates(*templateVersion, *templateDir)
+	if err != nil {
+		log.Fatalf("Erro ao carregar templates de texto: %v", err)
+	}
+	crawler.UseTextTemplates(templates)
+
+	cfg := config.Load()
+	dbConn, err := db.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Erro ao conectar no banco de dados (db): %v", err)
+	}
+	if err := db.Migrate(dbConn); err != nil {
+		log.Fatalf("Erro ao aplicar migrations: %v", err)
+	}
+
+	repo := &repository.RawRepository{DB: dbConn}
+	stored, err := repo.ListStored()
+	if err != nil {
+		log.Fatalf("Erro ao listar produtos: %v", err)
+	}
+	log.Printf("Reprocessando %d produtos a partir do JSON armazenado (template %s)...", len(stored), templates.Version)
+
+	var changed, unchanged, failed int
+	for _, s := range stored {
+		p, err := crawler.DecodeRaw(s.Source, s.SourceURL, s.RawJSON)
+		if err != nil {
+			log.Printf("Erro ao decodificar produto %s: %v", s.ProdutoID, err)
+			failed++
+			continue
+		}
+		// A categoria vem do crawl que encontrou o produto, não do JSON
+		if p.CategoryPath == "" {
+			p.CategoryPath = s.CategoryPath
+		}
+
+		raw := crawler.ToRawProduct(p)
+		raw.ID = s.ID
+		raw.ProdutoID = s.ProdutoID
+		raw.CategoryID = s.CategoryID
+		if raw.SourceURL == "" {
+			raw.SourceURL = s.SourceURL
+		}
+
+		status, err := repo.Save(raw)
+		if err != nil {
+			log.Printf("Erro ao salvar produto %s: %v", s.ProdutoID, err)
+			failed++
+			continue
+		}
+		if status == repository.SaveUnchanged {
+			unchanged++
+		} else {
+			changed++
+		}
+
+		var variants []model.ProductVariant
+		for _, v := range crawler.ParseVariants(p.ID, p.Variants) {
+			variants = append(variants, model.ProductVariant{
+				ProdutoID:        s.ProdutoID,
+				VariantProdutoID: v.ProductID,
+				Label:            v.Label,
+				Attribute:        v.Attribute,
+			})
+		}
+		if err := repo.SaveVariants(s.ProdutoID, variants); err != nil {
+			log.Printf("Erro ao salvar variantes do produto %s: %v", s.ProdutoID, err)
+		}
+	}
+
+	log.Printf("Reprocessamento finalizado: %d alterados (aguardando embeddings), %d sem alteração, %d falhas", changed, unchanged, failed)
+}
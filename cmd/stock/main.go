@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"iaprj/internal/config"
+	"iaprj/internal/observability"
 	"iaprj/internal/repository"
 	"iaprj/internal/stock"
 
@@ -16,6 +17,8 @@ import (
 func main() {
 	cfg := config.Load()
 
+	observability.Start(cfg.MetricsPort)
+
 	log.Println("Iniciando serviço de atualização de estoque...")
 
 	// Conecta ao banco de dados
@@ -26,6 +29,7 @@ func main() {
 	defer pool.Close()
 
 	repo := &repository.VectorRepository{DB: pool}
+	priceRepo := &repository.PriceHistoryRepository{DB: pool}
 
 	// Busca produtos para atualizar
 	log.Println("Buscando produtos no banco de dados...")
@@ -49,17 +53,30 @@ func main() {
 
 				available := 0
 				isAvailable, err := stock.CheckProductAvailability(p.ProdutoID)
+				observability.StockChecksTotal.Inc()
 				if err != nil {
+					observability.StockErrorsTotal.WithLabelValues("check").Inc()
 					log.Printf("Erro ao verificar %s: %v", p.ProdutoID, err)
 				} else if isAvailable {
 					available = 1
 				}
 
 				if err := repo.UpdateStock(p.ProdutoID, available); err != nil {
+					observability.StockErrorsTotal.WithLabelValues("update").Inc()
 					log.Printf("Erro ao atualizar estoque para %s: %v", p.ProdutoID, err)
 				} else {
 					log.Printf("Produto %s atualizado. Estoque: %d", p.ProdutoID, available)
 				}
+
+				// Só registra a disponibilidade quando ela foi de fato verificada
+				if err == nil {
+					if available != p.Stock {
+						observability.StockFlipsTotal.WithLabelValues(stockLabel(available)).Inc()
+					}
+					if err := priceRepo.RecordAvailability(p.ProdutoID, available == 1); err != nil {
+						log.Printf("Erro ao registrar disponibilidade para %s: %v", p.ProdutoID, err)
+					}
+				}
 			}
 		}()
 	}
@@ -72,4 +89,12 @@ func main() {
 	wg.Wait()
 
 	log.Println("Atualização de estoque finalizada.")
+	observability.Push(cfg.PushgatewayURL, "stock")
+}
+
+func stockLabel(available int) string {
+	if available == 1 {
+		return "in_stock"
+	}
+	return "out_of_stock"
 }
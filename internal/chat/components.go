@@ -0,0 +1,49 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"iaprj/internal/model"
+	"iaprj/internal/repository"
+)
+
+// loadComponents busca a composição do kit; produtos avulsos não têm componentes.
+func loadComponents(catalogRepo *repository.CatalogRepository, produtoID string) []model.ProductComponent {
+	if catalogRepo == nil {
+		return nil
+	}
+	components, err := catalogRepo.GetComponents(produtoID)
+	if err != nil {
+		log.Printf("[ChatV2] Erro ao buscar componentes do kit %s: %v", produtoID, err)
+		return nil
+	}
+	return components
+}
+
+// formatComponents descreve cada unidade do kit com suas dimensões e peso reais,
+// usados pelo LLM para responder se o aparelho cabe no espaço do cliente.
+func formatComponents(components []model.ProductComponent) string {
+	if len(components) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Composição do kit:\n")
+	for _, c := range components {
+		name := c.Name
+		if name == "" {
+			name = c.ComponentSKU
+		}
+		sb.WriteString(fmt.Sprintf("  - %dx %s (%s)", c.Quantity, name, c.Role))
+		if c.Length > 0 || c.Width > 0 || c.Height > 0 {
+			sb.WriteString(fmt.Sprintf(" | Dimensões (C x L x A): %g x %g x %g", c.Length, c.Width, c.Height))
+		}
+		if c.Weight > 0 {
+			sb.WriteString(fmt.Sprintf(" | Peso: %g", c.Weight))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
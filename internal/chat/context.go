@@ -3,14 +3,12 @@ package chat
 import (
 	"context"
 	"fmt"
-	"iaprj/internal/config"
 	"log"
 	"regexp"
 	"strconv"
 	"strings"
 
-	openai "github.com/sashabaranov/go-openai"
-
+	"iaprj/internal/embeddings"
 	"iaprj/internal/model"
 	"iaprj/internal/repository"
 )
@@ -136,8 +134,8 @@ func buildContext(
 	history []model.ChatMessage,
 	vectorRepo *repository.VectorRepository,
 	session *SessionStore,
+	embedder embeddings.Embedder,
 ) (string, error) {
-	cfg := config.Load()
 	userMessage := req.Message
 
 	// Combina a mensagem atual com as últimas 3 mensagens do usuário para manter o contexto
@@ -201,20 +199,12 @@ func buildContext(
 	searchStrategy = "Semântica/Híbrida (Vetorial)"
 	log.Println("Iniciando busca Semântica/Híbrida...")
 	// 1. gerar embedding da pergunta
-	client := openai.NewClient(cfg.OpenAIKey)
-
-	embResp, err := client.CreateEmbeddings(
-		context.Background(),
-		openai.EmbeddingRequest{
-			Model: "text-embedding-3-small",
-			Input: searchQuery,
-		},
-	)
+	vectors, err := embedder.Embed(context.Background(), []string{searchQuery})
 	if err != nil {
 		return "", err
 	}
 
-	queryVector := embResp.Data[0].Embedding
+	queryVector := vectors[0]
 
 	// Marcas que queremos dar um "boost" na relevância (aparecerão primeiro se tiverem score similar)
 	// O multiplicador é aplicado na distância, então quanto MENOR o valor, MAIOR a prioridade.
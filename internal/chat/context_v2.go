@@ -10,6 +10,7 @@ import (
 
 	openai "github.com/sashabaranov/go-openai"
 
+	"iaprj/internal/embeddings"
 	"iaprj/internal/model"
 	"iaprj/internal/repository"
 )
@@ -21,6 +22,7 @@ type ExtractedFilters struct {
 	Voltagem   string `json:"voltagem"`
 	Tecnologia string `json:"tecnologia"`
 	Type       string `json:"type"`
+	Category   string `json:"category"`
 }
 
 // extractFiltersWithLLM usa a IA para identificar a intenção de busca e filtros estruturados.
@@ -37,7 +39,8 @@ Regras de Extração:
 4. **voltagem**: "110V" ou "220V".
 5. **tecnologia**: "Inverter" ou "Convencional".
 6. **type**: Tipo do aparelho. Valores aceitos: "Split", "Janela", "Portátil", "Cassete", "Piso Teto", "Multi Split".
-   - Se não for especificado, assuma "Split".
+   - Se não for especificado e o pedido for de ar condicionado, assuma "Split".
+7. **category**: Família de produto, apenas quando o cliente pedir algo que não é ar condicionado. Valores aceitos: "cortina-de-ar", "pecas", "refrigeracao-comercial". Para ar condicionado, deixe vazio.
 
 Retorne APENAS o JSON, sem markdown.`
 
@@ -106,15 +109,36 @@ func resolveProductReference(userMsg string, history []model.ChatMessage) string
 	return userMsg
 }
 
+// loadFullContent substitui o chunk encontrado pelo documento completo do produto.
+func loadFullContent(results []repository.VectorResult, vectorRepo *repository.VectorRepository) {
+	for i := range results {
+		chunks, err := vectorRepo.GetChunksByProductID(results[i].ProdutoID)
+		if err == nil && len(chunks) > 0 {
+			results[i].Content = strings.Join(chunks, "\n")
+		}
+	}
+}
+
 func buildContextV2(
 	req ChatRequest,
 	history []model.ChatMessage,
 	vectorRepo *repository.VectorRepository,
+	catalogRepo *repository.CatalogRepository,
+	priceRepo *repository.PriceHistoryRepository,
 	session *SessionStore,
 	client *openai.Client,
+	embedder embeddings.Embedder,
 ) (string, error) {
 	//cfg := config.Load()
 
+	// Perguntas sobre outra versão de um produto já mostrado ("tem esse em 220V?")
+	// são respondidas pela relação de variantes, sem nova busca semântica.
+	if attribute, label, ok := extractVariantRequest(req.Message); ok {
+		if contextText, found := buildVariantContext(req, attribute, label, vectorRepo, catalogRepo, priceRepo, session); found {
+			return contextText, nil
+		}
+	}
+
 	// Resolve referências a produtos anteriores (ex: "produto 2" -> "Ar Condicionado Samsung...")
 	userMessage := resolveProductReference(req.Message, history)
 	if userMessage != req.Message {
@@ -174,7 +198,13 @@ func buildContextV2(
 	if extracted.Tecnologia != "" {
 		filters["tecnologia"] = extracted.Tecnologia
 	}
-	if extracted.Type != "" {
+	if extracted.Category != "" {
+		// Outras famílias (cortinas de ar, peças...) são filtradas pelo caminho da categoria
+		filters["category_path"] = extracted.Category
+		if extracted.Type != "" {
+			filters["type"] = extracted.Type
+		}
+	} else if extracted.Type != "" {
 		filters["type"] = extracted.Type
 	} else {
 		filters["type"] = "Split" // Default
@@ -213,18 +243,12 @@ func buildContextV2(
 	tokenEstimate := charCount / 4
 	log.Printf("[Embedding] Payload Stats: %d caracteres | ~%d tokens estimados", charCount, tokenEstimate)
 
-	embResp, err := client.CreateEmbeddings(
-		context.Background(),
-		openai.EmbeddingRequest{
-			Model: "text-embedding-3-small",
-			Input: searchQuery,
-		},
-	)
+	vectors, err := embedder.Embed(context.Background(), []string{searchQuery})
 	if err != nil {
 		return "", err
 	}
-	log.Printf("[ChatV2] Embedding gerado com sucesso.")
-	queryVector := embResp.Data[0].Embedding
+	log.Printf("[ChatV2] Embedding gerado com sucesso (%s).", embedder.Model())
+	queryVector := vectors[0]
 
 	// Configuração de Boost de Marcas (igual ao chat v1)
 	boostedBrands := map[string]float64{
@@ -265,12 +289,7 @@ func buildContextV2(
 	}
 
 	// Enriquece os resultados com o conteúdo completo (todos os chunks)
-	for i := range finalResults {
-		chunks, err := vectorRepo.GetChunksByProductID(finalResults[i].ProdutoID)
-		if err == nil && len(chunks) > 0 {
-			finalResults[i].Content = strings.Join(chunks, "\n")
-		}
-	}
+	loadFullContent(finalResults, vectorRepo)
 
 	log.Printf("[ChatV2] Estratégia final: %s | Produtos selecionados: %d", searchStrategy, len(finalResults))
 
@@ -278,18 +297,35 @@ func buildContextV2(
 		return "Desculpe, não encontrei produtos correspondentes à sua busca.", nil
 	}
 
+	contextText, shown := formatProductsV2(finalResults, searchQuery, targetBTU, catalogRepo, priceRepo)
+	session.SetLastProducts(req.SessionID, shown)
+
+	return contextText, nil
+}
+
+// formatProductsV2 monta o texto de contexto com preços, frete e descrição de cada produto.
+// Também devolve os IDs na ordem em que foram numerados ("Item 1", "Item 2"...).
+func formatProductsV2(
+	finalResults []repository.VectorResult,
+	searchQuery string,
+	targetBTU int,
+	catalogRepo *repository.CatalogRepository,
+	priceRepo *repository.PriceHistoryRepository,
+) (string, []string) {
 	// 4. Montagem do Contexto (Preços e Formatação)
 	type productWithPrice struct {
 		result       repository.VectorResult
 		priceInfo    ToolPriceResponse
 		priceDetails string
+		kitDetails   string
 	}
 
 	var products []productWithPrice
 	cep := extractCEP(searchQuery)
 
 	for _, r := range finalResults {
-		priceInfo := GetPrice(ToolPriceRequest{ProdutoID: r.ProdutoID, CEP: cep, SalePrice: r.SalePrice, Length: r.Length, Weight: r.Weight, Width: r.Width, Height: r.Height})
+		components := loadComponents(catalogRepo, r.ProdutoID)
+		priceInfo := GetPrice(ToolPriceRequest{ProdutoID: r.ProdutoID, CEP: cep, SalePrice: r.SalePrice, Length: r.Length, Weight: r.Weight, Width: r.Width, Height: r.Height, Components: components})
 
 		if cep != "" && !priceInfo.Estoque {
 			continue
@@ -305,11 +341,15 @@ func buildContextV2(
 		} else {
 			priceDetails += " (para saber o frete e o estoque, por favor, informe seu CEP)"
 		}
+		if trend := priceTrendInfo(priceRepo, r.ProdutoID); trend != "" {
+			priceDetails += " | Histórico: " + trend
+		}
 
 		products = append(products, productWithPrice{
 			result:       r,
 			priceInfo:    priceInfo,
 			priceDetails: priceDetails,
+			kitDetails:   formatComponents(components),
 		})
 	}
 
@@ -325,12 +365,17 @@ func buildContextV2(
 	for i, p := range products {
 		cleanContent := reClean.ReplaceAllString(p.result.Content, "\n")
 		builder.WriteString(
-			fmt.Sprintf("Item %d:\nProduto: %s\nMarca: %s\nSpecs: %d BTUs, %s, %s\nDados de Venda: %s\nLink: %s\nImagem: %s\nDescrição: %s\n\n",
+			fmt.Sprintf("Item %d:\nProduto: %s\nMarca: %s\nSpecs: %d BTUs, %s, %s\nDados de Venda: %s\n%sLink: %s\nImagem: %s\nDescrição: %s\n\n",
 				i+1, strings.Split(p.result.Content, "\n")[0], p.result.Brand,
 				p.result.Btus, p.result.Ciclo, p.result.Tecnologia,
-				p.priceDetails, p.result.SourceURL, p.result.ImageURL, strings.TrimSpace(cleanContent)),
+				p.priceDetails, p.kitDetails, p.result.SourceURL, p.result.ImageURL, strings.TrimSpace(cleanContent)),
 		)
 	}
 
-	return builder.String(), nil
+	shown := make([]string, len(products))
+	for i, p := range products {
+		shown[i] = p.result.ProdutoID
+	}
+
+	return builder.String(), shown
 }
@@ -6,10 +6,12 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	openai "github.com/sashabaranov/go-openai"
 
+	"iaprj/internal/embeddings"
 	"iaprj/internal/model"
 	"iaprj/internal/repository"
 )
@@ -29,6 +31,7 @@ func Handler(
 	vectorRepo *repository.VectorRepository,
 	session *SessionStore,
 	client *openai.Client,
+	embedder embeddings.Embedder,
 ) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -38,7 +41,7 @@ func Handler(
 
 		history, _ := session.Get(req.SessionID)
 
-		contextText, err := buildContext(req, history, vectorRepo, session)
+		contextText, err := buildContext(req, history, vectorRepo, session, embedder)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -95,3 +98,21 @@ func (s *SessionStore) GetCalculatedBTU(sessionID string) (int, error) {
 	s.Client.Expire(ctx, key, 20*time.Minute)
 	return btu, nil
 }
+
+// SetLastProducts guarda os IDs dos produtos mostrados na última resposta, na ordem exibida.
+func (s *SessionStore) SetLastProducts(sessionID string, ids []string) {
+	key := "products:" + sessionID
+	s.Client.Set(ctx, key, strings.Join(ids, ","), sessionTTL)
+}
+
+func (s *SessionStore) GetLastProducts(sessionID string) ([]string, error) {
+	key := "products:" + sessionID
+	val, err := s.Client.Get(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if val == "" {
+		return nil, nil
+	}
+	return strings.Split(val, ","), nil
+}
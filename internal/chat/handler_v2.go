@@ -7,6 +7,7 @@ import (
 
 	openai "github.com/sashabaranov/go-openai"
 
+	"iaprj/internal/embeddings"
 	"iaprj/internal/model"
 	"iaprj/internal/repository"
 )
@@ -30,6 +31,8 @@ DIRETRIZES DE RESPOSTA:
    - Uma prioridade importante é ter o menor preço.
 3. **Dados de Venda:** As informações de preço, frete e estoque estão na seção [DADOS ADICIONAIS] de cada item. Use-as.
    - **OBRIGATÓRIO:** Se o CEP do cliente não foi fornecido no contexto, SEMPRE termine sua resposta com a frase: "Para confirmar a disponibilidade e o valor do frete para sua região, por favor, informe seu CEP."
+   - Em kits, use a "Composição do kit" para responder sobre dimensões, peso e espaço de instalação de cada unidade (evaporadora e condensadora), nunca as dimensões do kit como um todo.
+   - Quando houver "Histórico" nos dados de venda (ex: "preço caiu R$300 desde a semana passada"), mencione essa informação ao cliente. Nunca invente variações de preço.
 4. **Calculadora de BTUs:** Se o cliente não souber a capacidade necessária:
    - Pergunte: Área do ambiente (m²), Incidência de sol (Manhã ou Tarde) e Quantidade de pessoas.
    - O sistema fará o cálculo automaticamente quando esses dados forem fornecidos.
@@ -41,8 +44,11 @@ DIRETRIZES DE RESPOSTA:
 
 func HandlerV2(
 	vectorRepo *repository.VectorRepository,
+	catalogRepo *repository.CatalogRepository,
+	priceRepo *repository.PriceHistoryRepository,
 	session *SessionStore,
 	client *openai.Client,
+	embedder embeddings.Embedder,
 ) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -55,7 +61,7 @@ func HandlerV2(
 		history, _ := session.Get(req.SessionID)
 
 		// Usa buildContextV2 que prioriza busca SQL simples
-		contextText, err := buildContextV2(req, history, vectorRepo, session, client)
+		contextText, err := buildContextV2(req, history, vectorRepo, catalogRepo, priceRepo, session, client, embedder)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
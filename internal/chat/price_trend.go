@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"iaprj/internal/model"
+	"iaprj/internal/repository"
+)
+
+// priceTrendDays é a janela usada para comparar o preço no contexto do chat.
+const priceTrendDays = 7
+
+// minPriceChange ignora oscilações de centavos entre crawls.
+const minPriceChange = 1.0
+
+// priceTrendInfo busca o histórico do produto e descreve a variação de preço,
+// ou retorna "" quando não há mudança relevante.
+func priceTrendInfo(priceRepo *repository.PriceHistoryRepository, produtoID string) string {
+	if priceRepo == nil {
+		return ""
+	}
+	trend, err := priceRepo.GetPriceTrend(produtoID, priceTrendDays)
+	if err != nil {
+		log.Printf("[ChatV2] Erro ao buscar histórico de preço de %s: %v", produtoID, err)
+		return ""
+	}
+	if trend == nil {
+		return ""
+	}
+	return describePriceTrend(*trend, time.Now())
+}
+
+// describePriceTrend monta frases como "preço caiu R$300 desde a semana passada".
+func describePriceTrend(t model.PriceTrend, now time.Time) string {
+	var info string
+
+	change := t.Change()
+	if math.Abs(float64(change)) >= minPriceChange {
+		since := "a semana passada"
+		if now.Sub(t.PreviousAt) < time.Duration(t.Days-1)*24*time.Hour {
+			since = t.PreviousAt.Format("02/01")
+		}
+		if change < 0 {
+			info = fmt.Sprintf("preço caiu R$%.0f desde %s", -change, since)
+		} else {
+			info = fmt.Sprintf("preço subiu R$%.0f desde %s", change, since)
+		}
+	}
+
+	if t.LowestPrice > 0 && t.CurrentPrice-t.LowestPrice >= minPriceChange {
+		if info != "" {
+			info += "; "
+		}
+		info += fmt.Sprintf("menor preço nos últimos %d dias: R$%.2f em %s", t.Days, t.LowestPrice, t.LowestAt.Format("02/01"))
+	}
+
+	return info
+}
@@ -9,6 +9,8 @@ import (
 	"math/rand"
 	"net/http"
 	"strings"
+
+	"iaprj/internal/model"
 )
 
 type ToolPriceRequest struct {
@@ -20,6 +22,9 @@ type ToolPriceRequest struct {
 	Width     float32 `json:"width"`
 	Height    float32 `json:"height"`
 	Stock     int     `json:"stock"`
+	// Components são as unidades de um kit; quando presentes o frete é cotado
+	// com as dimensões de cada uma em vez das dimensões do kit.
+	Components []model.ProductComponent `json:"components,omitempty"`
 }
 
 type ToolPriceResponse struct {
@@ -128,17 +133,7 @@ type AddressData struct {
 func GetPrice(req ToolPriceRequest) ToolPriceResponse {
 	if req.CEP != "" {
 		// Tenta calcular o frete via API
-		item := Item{
-			ID:          req.ProdutoID,
-			Qtd:         1,
-			Width:       req.Width,
-			Height:      req.Height,
-			Weight:      req.Weight,
-			Length:      req.Length,
-			Price:       req.SalePrice,
-			ProductType: "ar-condicionado",
-		}
-		if resp, err := CalculateShipping(req.CEP, []Item{item}); err == nil && len(resp.ShippingGroups) > 0 {
+		if resp, err := CalculateShipping(req.CEP, shippingItems(req)); err == nil && len(resp.ShippingGroups) > 0 {
 			if resp.ShippingGroups[0].LocationID == "UNAVAILABLE" {
 				return ToolPriceResponse{
 					ProdutoID: req.ProdutoID,
@@ -167,6 +162,51 @@ func GetPrice(req ToolPriceRequest) ToolPriceResponse {
 	}
 }
 
+// shippingItems monta os volumes da cotação: um item por componente nos kits,
+// com o preço do kit rateado quando o componente não tem preço próprio.
+func shippingItems(req ToolPriceRequest) []Item {
+	if len(req.Components) == 0 {
+		return []Item{{
+			ID:          req.ProdutoID,
+			Qtd:         1,
+			Width:       req.Width,
+			Height:      req.Height,
+			Weight:      req.Weight,
+			Length:      req.Length,
+			Price:       req.SalePrice,
+			ProductType: "ar-condicionado",
+		}}
+	}
+
+	units := 0
+	for _, c := range req.Components {
+		units += c.Quantity
+	}
+
+	items := make([]Item, 0, len(req.Components))
+	for _, c := range req.Components {
+		id := c.ComponentProdutoID
+		if id == "" {
+			id = c.ComponentSKU
+		}
+		price := c.SalePrice
+		if price == 0 && units > 0 {
+			price = req.SalePrice / float32(units)
+		}
+		items = append(items, Item{
+			ID:          id,
+			Qtd:         c.Quantity,
+			Width:       c.Width,
+			Height:      c.Height,
+			Weight:      c.Weight,
+			Length:      c.Length,
+			Price:       price,
+			ProductType: "ar-condicionado",
+		})
+	}
+	return items
+}
+
 func CalculateShipping(cep string, items []Item) (*ShippingResponse, error) {
 	const apiURL = "https://api.frigelar.com.br/fgl_svc_logistic/logistic"
 
@@ -0,0 +1,153 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"iaprj/internal/repository"
+)
+
+var (
+	// Pergunta explícita por outra versão de um produto já mostrado: "tem esse em
+	// 220V", "vem ele na versão quente e frio", "versão 220V desse", "o produto 2".
+	// Pronomes soltos ("para este quarto") não contam.
+	reVariantRef = regexp.MustCompile(`(?i)(?:\b(?:tem|existe|vem|há|ha|sai)\s+(?:esse|este|essa|esta|ele|ela|o\s+mesmo|a\s+mesma)(?:\s+(?:modelo|produto|aparelho))?\s+(?:em|na|no|com|de|para)\b|\b(?:versão|versao|variante|opção|opcao)\s+(?:\S+\s+){0,2}(?:desse|deste|dessa|desta|dele|dela|do\s+mesmo|da\s+mesma)\b|\b(?:produto|item|opção|opcao)\s+\d+\b)`)
+	// Tipo de aparelho pedido na própria mensagem, o que indica uma busca nova
+	reProductType = regexp.MustCompile(`(?i)\b(?:split|janela|portátil|portatil|piso\s*teto|cassete|multi\s*split|inverter)\b`)
+	reVariantIdx  = regexp.MustCompile(`(?i)\b(?:produto|item|opção|opcao)\s+(\d+)\b`)
+	reVoltage     = regexp.MustCompile(`(?i)\b(110|127|220|380)\s*v\b`)
+)
+
+// extractVariantRequest identifica perguntas sobre outra versão de um produto já
+// mostrado (ex: "tem esse em 220V?", "o produto 2 tem quente e frio?") e retorna
+// o atributo e o valor pedidos.
+// Mensagens que trazem a própria capacidade ou tipo de aparelho são buscas novas.
+func extractVariantRequest(msg string) (attribute, label string, ok bool) {
+	if !reVariantRef.MatchString(msg) {
+		return "", "", false
+	}
+	if extractTargetBTU(msg) > 0 || reProductType.MatchString(msg) {
+		return "", "", false
+	}
+	if _, ok := tryCalculateBTU(msg); ok {
+		return "", "", false
+	}
+
+	if m := reVoltage.FindStringSubmatch(msg); len(m) > 1 {
+		return "voltagem", m[1] + "V", true
+	}
+
+	lower := strings.ToLower(msg)
+	if strings.Contains(lower, "quente e frio") || strings.Contains(lower, "quente/frio") {
+		return "ciclo", "Quente/Frio", true
+	}
+	if strings.Contains(lower, "só frio") || strings.Contains(lower, "apenas frio") {
+		return "ciclo", "Frio", true
+	}
+	return "", "", false
+}
+
+// matchesVariantLabel compara o valor de uma variante com o pedido do cliente,
+// tratando 110V e 127V como a mesma tensão.
+func matchesVariantLabel(attribute, value, want string) bool {
+	v := strings.ToLower(strings.ReplaceAll(value, " ", ""))
+	w := strings.ToLower(strings.ReplaceAll(want, " ", ""))
+
+	if attribute == "voltagem" {
+		normalize := func(s string) string {
+			if m := reVoltage.FindStringSubmatch(s); len(m) > 1 {
+				if m[1] == "127" {
+					return "110"
+				}
+				return m[1]
+			}
+			return s
+		}
+		return normalize(v) == normalize(w)
+	}
+
+	// Ciclo: "Quente/Frio" contém "frio", então compara pela presença de "quente"
+	return strings.Contains(v, "quente") == strings.Contains(w, "quente") && strings.Contains(v, "frio")
+}
+
+// buildVariantContext troca os produtos mostrados na última resposta pelas suas
+// variantes com o valor pedido. Retorna false quando não há variante conhecida,
+// para que a busca normal seja feita.
+func buildVariantContext(
+	req ChatRequest,
+	attribute, label string,
+	vectorRepo *repository.VectorRepository,
+	catalogRepo *repository.CatalogRepository,
+	priceRepo *repository.PriceHistoryRepository,
+	session *SessionStore,
+) (string, bool) {
+	lastIDs, err := session.GetLastProducts(req.SessionID)
+	if err != nil || len(lastIDs) == 0 {
+		return "", false
+	}
+
+	// "o produto 2 tem em 220V?" restringe a um único item da lista anterior
+	if m := reVariantIdx.FindStringSubmatch(req.Message); len(m) > 1 {
+		idx, _ := strconv.Atoi(m[1])
+		if idx < 1 || idx > len(lastIDs) {
+			return "", false
+		}
+		lastIDs = lastIDs[idx-1 : idx]
+	}
+
+	current, err := vectorRepo.GetProductsByIDs(lastIDs)
+	if err != nil {
+		log.Printf("[ChatV2] Erro ao carregar produtos da sessão: %v", err)
+		return "", false
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, p := range current {
+		// O próprio produto já atende ao pedido
+		own := p.Voltagem
+		if attribute == "ciclo" {
+			own = p.Ciclo
+		}
+		if matchesVariantLabel(attribute, own, label) && !seen[p.ProdutoID] {
+			ids = append(ids, p.ProdutoID)
+			seen[p.ProdutoID] = true
+			continue
+		}
+
+		variants, err := catalogRepo.GetVariants(p.ProdutoID)
+		if err != nil {
+			log.Printf("[ChatV2] Erro ao buscar variantes de %s: %v", p.ProdutoID, err)
+			continue
+		}
+		for _, v := range variants {
+			if v.Attribute == attribute && matchesVariantLabel(attribute, v.Label, label) && !seen[v.VariantProdutoID] {
+				ids = append(ids, v.VariantProdutoID)
+				seen[v.VariantProdutoID] = true
+				break
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		log.Printf("[ChatV2] Nenhuma variante %s=%s para os produtos %v", attribute, label, lastIDs)
+		return "", false
+	}
+
+	results, err := vectorRepo.GetProductsByIDs(ids)
+	if err != nil || len(results) == 0 {
+		return "", false
+	}
+	loadFullContent(results, vectorRepo)
+
+	log.Printf("[ChatV2] Estratégia final: Variantes (%s=%s) | Produtos selecionados: %d", attribute, label, len(results))
+
+	contextText, shown := formatProductsV2(results, req.Message, 0, catalogRepo, priceRepo)
+	session.SetLastProducts(req.SessionID, shown)
+
+	header := fmt.Sprintf("SISTEMA: O cliente pediu a versão %s dos produtos mostrados anteriormente. Os itens abaixo são essas versões do mesmo modelo.\n\n", label)
+	return header + contextText, true
+}
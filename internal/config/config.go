@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strconv"
 
 	"github.com/joho/godotenv"
 )
@@ -11,8 +12,29 @@ type Config struct {
 	RedisURL        string
 	OpenAIKey       string
 	MetricsPort     string
+	PushgatewayURL  string // jobs em lote enviam as métricas ao terminar
 	WorkerCount     int
 	RehydrationMode string
+
+	// Chunking dos documentos para embedding, em tokens
+	ChunkMaxTokens     int
+	ChunkOverlapTokens int
+
+	// Backend de embeddings: "openai", "compatible" (Ollama, LocalAI...) ou "hash".
+	// O modelo em uso vem do índice ativo (embedding_indexes); EmbeddingModel e
+	// EmbeddingDimensions são o padrão de um novo índice (-build-index).
+	EmbeddingProvider   string
+	EmbeddingBaseURL    string
+	EmbeddingModel      string
+	EmbeddingDimensions int
+
+	// Orçamento da API de embeddings por minuto, dividido entre os workers
+	EmbeddingTPM        int
+	EmbeddingRPM        int
+	EmbeddingMaxRetries int
+
+	// Falhas de um produto antes de ele exigir inspeção manual
+	EmbeddingMaxAttempts int
 }
 
 func Load() *Config {
@@ -25,8 +47,23 @@ func Load() *Config {
 		RedisURL:        os.Getenv("REDIS_URL"),
 		OpenAIKey:       os.Getenv("OPENAI_API_KEY"),
 		MetricsPort:     getEnv("METRICS_PORT", "9090"),
-		WorkerCount:     5,
+		PushgatewayURL:  os.Getenv("PUSHGATEWAY_URL"),
+		WorkerCount:     getEnvInt("WORKER_COUNT", 5),
 		RehydrationMode: getEnv("REHYDRATION_MODE", "first"), // Pode ser "full" ou "first"
+
+		ChunkMaxTokens:     getEnvInt("CHUNK_MAX_TOKENS", 400),
+		ChunkOverlapTokens: getEnvInt("CHUNK_OVERLAP_TOKENS", 40),
+
+		EmbeddingProvider:   getEnv("EMBEDDING_PROVIDER", "openai"),
+		EmbeddingBaseURL:    os.Getenv("EMBEDDING_BASE_URL"),
+		EmbeddingModel:      getEnv("EMBEDDING_MODEL", "text-embedding-3-small"),
+		EmbeddingDimensions: getEnvInt("EMBEDDING_DIMENSIONS", 1536),
+
+		EmbeddingTPM:        getEnvInt("EMBEDDING_TPM", 1000000),
+		EmbeddingRPM:        getEnvInt("EMBEDDING_RPM", 3000),
+		EmbeddingMaxRetries: getEnvInt("EMBEDDING_MAX_RETRIES", 5),
+
+		EmbeddingMaxAttempts: getEnvInt("EMBEDDING_MAX_ATTEMPTS", 5),
 	}
 }
 
@@ -36,3 +73,10 @@ func getEnv(k, d string) string {
 	}
 	return d
 }
+
+func getEnvInt(k string, d int) int {
+	if v, err := strconv.Atoi(os.Getenv(k)); err == nil {
+		return v
+	}
+	return d
+}
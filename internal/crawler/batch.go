@@ -1,17 +1,26 @@
 package crawler
 
-import "log"
+import (
+	"fmt"
+	"log"
+)
 
-func CrawlBatch(productIDs []string, batchSize int, handler func(OCCProduct)) {
+// CrawlBatch busca os produtos em lotes. Lotes que falham mesmo após os retries
+// do transport são recolocados no fim da fila e tentados mais uma vez; se ainda
+// assim falharem, os IDs perdidos são devolvidos no erro.
+func (c *OCCClient) CrawlBatch(productIDs []string, batchSize int, handler func(OCCProduct)) error {
+	var failed [][]string
 	for i := 0; i < len(productIDs); i += batchSize {
 		end := i + batchSize
 		if end > len(productIDs) {
 			end = len(productIDs)
 		}
 
-		products, err := FetchProductsByIDs(productIDs[i:end])
+		batch := productIDs[i:end]
+		products, err := c.FetchProductsByIDs(batch)
 		if err != nil {
 			log.Println("Erro batch:", err)
+			failed = append(failed, batch)
 			continue
 		}
 
@@ -19,4 +28,27 @@ func CrawlBatch(productIDs []string, batchSize int, handler func(OCCProduct)) {
 			handler(p)
 		}
 	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	log.Printf("Retentando %d lotes que falharam", len(failed))
+	var lost []string
+	for _, batch := range failed {
+		products, err := c.FetchProductsByIDs(batch)
+		if err != nil {
+			log.Println("Erro batch (retentativa):", err)
+			lost = append(lost, batch...)
+			continue
+		}
+		for _, p := range products {
+			handler(p)
+		}
+	}
+
+	if len(lost) > 0 {
+		return fmt.Errorf("%d produtos não puderam ser buscados: %v", len(lost), lost)
+	}
+	return nil
 }
@@ -3,57 +3,143 @@ package crawler
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+
+	"iaprj/internal/observability"
 )
 
-// FetchProductsByCategory fetches all products for a given category, handling pagination.
-func FetchProductsByCategory(categoryID string, handler func(OCCProduct)) error {
-	baseURL := "https://www.frigelar.com.br"
-	nextURL := fmt.Sprintf("%s/ccstoreui/v1/products?categoryId=%s&includeChildren=true&limit=50", baseURL, categoryID)
+// CategoryURL returns the first page URL for a category listing.
+func (c *OCCClient) CategoryURL(categoryID string, includeChildren bool) string {
 	//https://www.frigelar.com.br/ccstoreui/v1/products?categoryId=ar-condicionado&includeChildren=true&page=0&offset=0&limit=2
+	return fmt.Sprintf("%s/ccstoreui/v1/products?categoryId=%s&includeChildren=%t&limit=50", c.BaseURL, categoryID, includeChildren)
+}
+
+// FetchProductsByCategory fetches all products for a given category and its children, handling pagination.
+func (c *OCCClient) FetchProductsByCategory(categoryID string, handler func(OCCProduct)) error {
+	return c.FetchCategoryPages(c.CategoryURL(categoryID, true), handler, nil)
+}
+
+// FetchCategoryPages walks the category listing starting at startURL. After every
+// page is handled, onPage (if set) receives the URL of the next page, or "" on the last one.
+// A page that still fails after the transport retries is skipped (the next one is found by
+// offset) and fetched again at the end, like CrawlBatch does with ID batches; only pages
+// that fail twice make the walk return an error.
+func (c *OCCClient) FetchCategoryPages(startURL string, handler func(OCCProduct), onPage func(nextURL string)) error {
+	nextURL := startURL
+	total := -1
+	var failed []string
 
 	for nextURL != "" {
-		req, err := http.NewRequest("GET", nextURL, nil)
+		pageURL := nextURL
+		result, err := c.fetchCategoryPage(pageURL)
 		if err != nil {
-			return fmt.Errorf("failed to create request for %s: %w", nextURL, err)
+			// Sem nenhuma página lida não há como saber o tamanho da categoria
+			if total < 0 {
+				return err
+			}
+			log.Printf("[Crawler] Pulando página da categoria após falha: %v", err)
+			failed = append(failed, pageURL)
+			nextURL = skipPage(pageURL, total)
+		} else {
+			for _, p := range result.Items {
+				handler(p)
+			}
+			observability.CrawlerPagesTotal.Inc()
+			total = result.TotalResults
+			nextURL = c.nextPageURL(result)
 		}
-		req.Header.Set("User-Agent", "Mozilla/5.0")
-		req.Header.Set("Accept", "application/json")
 
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("failed to fetch %s: %w", nextURL, err)
+		if onPage != nil {
+			onPage(nextURL)
 		}
-		defer resp.Body.Close()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("OCC status %d for %s", resp.StatusCode, nextURL)
-		}
+	if len(failed) == 0 {
+		return nil
+	}
 
-		var result OCCCaregoryResponse
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return fmt.Errorf("failed to decode response from %s: %w", nextURL, err)
+	log.Printf("Retentando %d páginas da categoria que falharam", len(failed))
+	var lost []string
+	for _, pageURL := range failed {
+		result, err := c.fetchCategoryPage(pageURL)
+		if err != nil {
+			log.Printf("[Crawler] Erro na página (retentativa): %v", err)
+			lost = append(lost, pageURL)
+			continue
 		}
-
 		for _, p := range result.Items {
 			handler(p)
 		}
+		observability.CrawlerPagesTotal.Inc()
+	}
+
+	if len(lost) > 0 {
+		return fmt.Errorf("%d páginas da categoria não puderam ser buscadas: %v", len(lost), lost)
+	}
+	return nil
+}
 
-		// Find next page link
-		nextURL = ""
-		for _, link := range result.Links {
-			if link.Rel == "next" {
-				href := strings.TrimSpace(link.Href)
-				if strings.HasPrefix(href, "/") {
-					nextURL = baseURL + href
-				} else {
-					nextURL = href
-				}
-				break
+// nextPageURL returns the "next" link of the page, or "" on the last one.
+func (c *OCCClient) nextPageURL(result *OCCCaregoryResponse) string {
+	for _, link := range result.Links {
+		if link.Rel == "next" {
+			href := strings.TrimSpace(link.Href)
+			if strings.HasPrefix(href, "/") {
+				return c.BaseURL + href
 			}
+			return href
 		}
 	}
+	return ""
+}
 
-	return nil
+// skipPage returns the page after a failed one by adding limit to its offset,
+// since the links of the failed page are not available.
+func skipPage(pageURL string, total int) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil || limit <= 0 {
+		return ""
+	}
+	offset += limit
+	if offset >= total {
+		return ""
+	}
+	q.Set("offset", strconv.Itoa(offset))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (c *OCCClient) fetchCategoryPage(pageURL string) (*OCCCaregoryResponse, error) {
+	req, err := http.NewRequest("GET", pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", pageURL, err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", req.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCC status %d for %s", resp.StatusCode, req.URL)
+	}
+
+	var result OCCCaregoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %w", req.URL, err)
+	}
+	return &result, nil
 }
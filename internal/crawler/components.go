@@ -0,0 +1,121 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Component é uma unidade de um kit (ex: evaporadora e condensadora), com as
+// dimensões e o peso reais do SKU componente.
+type Component struct {
+	SKU       string  `json:"sku"`
+	ProductID string  `json:"product_id,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	Role      string  `json:"role,omitempty"`
+	Quantity  int     `json:"quantity"`
+	Length    float32 `json:"length,omitempty"`
+	Weight    float32 `json:"weight,omitempty"`
+	Width     float32 `json:"width,omitempty"`
+	Height    float32 `json:"height,omitempty"`
+	SalePrice float32 `json:"sale_price,omitempty"`
+	// Resolved indica que os dados do SKU foram buscados na loja
+	Resolved bool `json:"resolved"`
+}
+
+type occSKUResponse struct {
+	Items []OCCSKU `json:"items"`
+}
+
+// FetchSKUs busca SKUs pelo repositoryId.
+func (c *OCCClient) FetchSKUs(ids []string) ([]OCCSKU, error) {
+	url := fmt.Sprintf("%s/ccstoreui/v1/skus?skuIds=%s&pageSize=%d", c.BaseURL, strings.Join(ids, ","), len(ids))
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("OCC status %d", resp.StatusCode)
+	}
+
+	var result occSKUResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+// ResolveComponents completa os componentes do kit com nome, produto, dimensões
+// e peso de cada SKU. Componentes não encontrados ficam com Resolved = false.
+func (c *OCCClient) ResolveComponents(p *Product) error {
+	if len(p.Components) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(p.Components))
+	for i, comp := range p.Components {
+		ids[i] = comp.SKU
+	}
+	skus, err := c.FetchSKUs(ids)
+	if err != nil {
+		return fmt.Errorf("componentes do kit %s: %w", p.ID, err)
+	}
+
+	byID := make(map[string]OCCSKU, len(skus))
+	for _, sku := range skus {
+		byID[sku.RepositoryID] = sku
+	}
+	for i := range p.Components {
+		comp := &p.Components[i]
+		sku, ok := byID[comp.SKU]
+		if !ok {
+			continue
+		}
+		if sku.DisplayName != "" {
+			comp.Name = sku.DisplayName
+		}
+		if len(sku.ParentProducts) > 0 {
+			comp.ProductID = sku.ParentProducts[0].key()
+		}
+		comp.Length, comp.Weight, comp.Width, comp.Height = sku.Length, sku.Weight, sku.Width, sku.Height
+		comp.SalePrice = sku.SalePrice
+		if comp.SalePrice == 0 {
+			comp.SalePrice = sku.ListPrice
+		}
+		comp.Role = ComponentRole(comp.Name)
+		comp.Resolved = true
+	}
+	return nil
+}
+
+// ComponentsResolved indica se todos os componentes do kit foram resolvidos.
+// Sem isso o crawler mantém a composição já gravada.
+func (p Product) ComponentsResolved() bool {
+	for _, comp := range p.Components {
+		if !comp.Resolved {
+			return false
+		}
+	}
+	return true
+}
+
+// ComponentRole classifica o componente pelo nome.
+func ComponentRole(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.Contains(lower, "evaporadora"), strings.Contains(lower, "unidade interna"):
+		return "evaporadora"
+	case strings.Contains(lower, "condensadora"), strings.Contains(lower, "unidade externa"):
+		return "condensadora"
+	default:
+		return "componente"
+	}
+}
@@ -1,20 +1,34 @@
 package crawler
 
 import (
+	"fmt"
 	"io"
 	"net/http"
-	"time"
 )
 
-var defaultHTTPClient = &http.Client{Timeout: 60 * time.Second}
-
+// Fetch baixa uma página da loja usando o mesmo cliente com retry e limite de
+// requisições da API.
 func Fetch(url string) (string, error) {
-	resp, err := defaultHTTPClient.Get(url)
+	return fetchWith(httpClient, url)
+}
+
+func fetchWith(client *http.Client, url string) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d for %s", resp.StatusCode, url)
+	}
+
 	b, err := io.ReadAll(resp.Body)
 	return string(b), err
 }
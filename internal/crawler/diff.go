@@ -0,0 +1,180 @@
+package crawler
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"iaprj/internal/model"
+)
+
+// reNameBTU encontra a capacidade escrita no nome do produto ("Split 12.000 BTUs").
+var reNameBTU = regexp.MustCompile(`(?i)(\d{1,3}(?:\.\d{3})+|\d{4,6})\s*BTU`)
+
+// FieldDiff é a diferença de um campo entre o banco e o crawl atual.
+type FieldDiff struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// ProductDiff descreve um produto adicionado, removido ou alterado.
+type ProductDiff struct {
+	ProdutoID string      `json:"produto_id"`
+	Name      string      `json:"name,omitempty"`
+	Category  string      `json:"category,omitempty"`
+	Fields    []FieldDiff `json:"fields,omitempty"`
+}
+
+// CatalogWarning aponta um erro provável de cadastro no OCC.
+type CatalogWarning struct {
+	ProdutoID string `json:"produto_id"`
+	Message   string `json:"message"`
+}
+
+// DiffReport é o resultado de um crawl em modo -dry-run.
+type DiffReport struct {
+	Added     []ProductDiff    `json:"added"`
+	Removed   []ProductDiff    `json:"removed"`
+	Changed   []ProductDiff    `json:"changed"`
+	Unchanged int              `json:"unchanged"`
+	Warnings  []CatalogWarning `json:"warnings"`
+}
+
+// CatalogDiff compara os produtos crawleados com o que está em
+// product_raw_knowledge sem gravar nada. Pode ser alimentado por vários workers.
+type CatalogDiff struct {
+	mu         sync.Mutex
+	existing   map[string]model.RawProduct
+	seen       map[string]bool
+	categories map[string]bool
+	report     DiffReport
+}
+
+func NewCatalogDiff(existing []model.RawProduct) *CatalogDiff {
+	d := &CatalogDiff{
+		existing:   make(map[string]model.RawProduct, len(existing)),
+		seen:       make(map[string]bool),
+		categories: make(map[string]bool),
+	}
+	for _, p := range existing {
+		d.existing[p.ProdutoID] = p
+	}
+	return d
+}
+
+// Add compara um produto crawleado com a versão gravada.
+func (d *CatalogDiff) Add(p Product) {
+	// O caminho já gravado prevalece: a troca da categoria principal só acontece
+	// quando o produto sai dela, o que o dry-run não tem como saber
+	if old, ok := d.existing[p.ID]; ok && old.CategoryPath != "" {
+		p.CategoryPath = old.CategoryPath
+	}
+	raw := ToRawProduct(p)
+	warnings := Validate(p)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.seen[p.ID] {
+		return
+	}
+	d.seen[p.ID] = true
+	d.report.Warnings = append(d.report.Warnings, warnings...)
+
+	old, ok := d.existing[p.ID]
+	if !ok {
+		d.report.Added = append(d.report.Added, ProductDiff{ProdutoID: p.ID, Name: p.DisplayName, Category: p.CategoryPath})
+		return
+	}
+
+	fields := compareFields(old, raw)
+	if len(fields) == 0 {
+		d.report.Unchanged++
+		return
+	}
+	d.report.Changed = append(d.report.Changed, ProductDiff{ProdutoID: p.ID, Name: p.DisplayName, Category: p.CategoryPath, Fields: fields})
+}
+
+// CategoryCompleted informa que a categoria foi crawleada por inteiro, então
+// seus produtos gravados que não apareceram contam como removidos.
+func (d *CatalogDiff) CategoryCompleted(categoryID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.categories[categoryID] = true
+}
+
+// Report fecha a comparação e devolve o relatório ordenado por produto.
+func (d *CatalogDiff) Report() DiffReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	report := d.report
+	report.Removed = nil
+	for id, p := range d.existing {
+		if !d.seen[id] && p.CategoryID != "" && d.categories[p.CategoryID] {
+			report.Removed = append(report.Removed, ProductDiff{ProdutoID: id, Category: p.CategoryID})
+		}
+	}
+
+	for _, list := range [][]ProductDiff{report.Added, report.Removed, report.Changed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].ProdutoID < list[j].ProdutoID })
+	}
+	sort.SliceStable(report.Warnings, func(i, j int) bool { return report.Warnings[i].ProdutoID < report.Warnings[j].ProdutoID })
+	return report
+}
+
+func compareFields(old, cur model.RawProduct) []FieldDiff {
+	var fields []FieldDiff
+	add := func(field, o, n string) {
+		if o != n {
+			fields = append(fields, FieldDiff{Field: field, Old: o, New: n})
+		}
+	}
+
+	add("preço", fmt.Sprintf("%.2f", old.SalePrice), fmt.Sprintf("%.2f", cur.SalePrice))
+	add("btus", fmt.Sprint(old.Btus), fmt.Sprint(cur.Btus))
+	add("voltagem", old.Voltagem, cur.Voltagem)
+	add("ciclo", old.Ciclo, cur.Ciclo)
+	add("marca", old.Brand, cur.Brand)
+	add("imagem", old.ImageURL, cur.ImageURL)
+
+	// Outras mudanças no texto só aparecem pelo hash, calculado como na gravação
+	if hash := model.ContentHash(cur.Content); len(fields) == 0 && old.ContentHash != "" && old.ContentHash != hash {
+		fields = append(fields, FieldDiff{Field: "conteúdo", Old: old.ContentHash[:12], New: hash[:12]})
+	}
+	return fields
+}
+
+// Validate aponta erros comuns de cadastro: marca ausente e capacidade em BTUs
+// ausente, fora da faixa ou diferente da escrita no nome do produto.
+func Validate(p Product) []CatalogWarning {
+	var warnings []CatalogWarning
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, CatalogWarning{ProdutoID: p.ID, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if strings.TrimSpace(p.Brand) == "" {
+		warn("marca ausente")
+	}
+	if p.SalePrice <= 0 {
+		warn("sem preço de venda")
+	}
+
+	btus := ParseBTU(p.Btus)
+	isAC := strings.Contains(strings.ToLower(p.Categoria), "ar-condicionado") || strings.TrimSpace(p.Btus) != ""
+	switch {
+	case isAC && btus == 0:
+		warn("capacidade em BTUs ausente ou inválida (%q)", p.Btus)
+	case btus > 0 && (btus < 5000 || btus > 100000):
+		warn("capacidade fora da faixa esperada: %d BTUs", btus)
+	}
+	if m := reNameBTU.FindStringSubmatch(p.DisplayName); m != nil && btus > 0 {
+		if nameBTU := ParseBTU(m[1]); nameBTU != btus {
+			warn("BTUs do nome (%d) diferente do campo de capacidade (%d)", nameBTU, btus)
+		}
+	}
+	return warnings
+}
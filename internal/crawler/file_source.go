@@ -0,0 +1,192 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// reThousands reconhece números só com separador de milhar ("12.000", "1.234.567").
+var reThousands = regexp.MustCompile(`^\d{1,3}(?:\.\d{3})+$`)
+
+// FileSource lê um catálogo exportado por outro canal. Aceita um array JSON
+// (ou um objeto por linha) e CSV com cabeçalho, ambos usando os nomes de campo
+// das tags json de Product (id, display_name, brand, btus, sale_price...).
+// Linhas do CSV com números inválidos são descartadas e passadas a OnInvalid.
+type FileSource struct {
+	Path      string
+	OnInvalid func(line int, err error)
+}
+
+func (s *FileSource) Name() string {
+	return "file:" + filepath.Base(s.Path)
+}
+
+func (s *FileSource) Crawl(handler func(Product)) error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(s.Path)) {
+	case ".csv":
+		return s.crawlCSV(f, handler)
+	case ".json", ".ndjson", ".jsonl":
+		return s.crawlJSON(f, handler)
+	default:
+		return fmt.Errorf("formato de arquivo não suportado: %s", s.Path)
+	}
+}
+
+func (s *FileSource) crawlJSON(r io.Reader, handler func(Product)) error {
+	br := bufio.NewReader(r)
+	first, err := firstNonSpace(br)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(br)
+
+	// Array JSON: [ {...}, {...} ]
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			if err := s.emitRaw(raw, handler); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Um objeto por linha
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := s.emitRaw(raw, handler); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *FileSource) crawlCSV(r io.Reader, handler func(Product)) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Monta um objeto JSON com os nomes do cabeçalho para reaproveitar as tags de Product
+		fields := make(map[string]interface{}, len(header))
+		var invalid error
+		for i, name := range header {
+			if i >= len(record) {
+				break
+			}
+			name = strings.TrimSpace(name)
+			value := strings.TrimSpace(record[i])
+			if !isNumericField(name) {
+				fields[name] = value
+				continue
+			}
+			if value == "" {
+				continue
+			}
+			f, err := parseDecimal(value)
+			if err != nil {
+				invalid = fmt.Errorf("%s inválido %q: %w", name, value, err)
+				break
+			}
+			fields[name] = f
+		}
+
+		// Uma linha com preço ilegível não pode virar produto com preço 0
+		if invalid != nil {
+			line, _ := reader.FieldPos(0)
+			if s.OnInvalid != nil {
+				s.OnInvalid(line, invalid)
+			} else {
+				log.Printf("Linha %d de %s ignorada: %v", line, s.Path, invalid)
+			}
+			continue
+		}
+
+		b, _ := json.Marshal(fields)
+		if err := s.emitRaw(b, handler); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *FileSource) emitRaw(raw []byte, handler func(Product)) error {
+	p, err := DecodeRaw("file", "", raw)
+	if err != nil {
+		return err
+	}
+	if p.ID != "" {
+		handler(p)
+	}
+	return nil
+}
+
+// firstNonSpace devolve o primeiro caractere relevante sem consumi-lo do reader.
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// parseDecimal aceita números no formato brasileiro ("1.234,56", "12.000") e
+// com ponto decimal ("1234.56").
+func parseDecimal(s string) (float64, error) {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "R$"))
+	switch {
+	case strings.Contains(s, ","):
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.Replace(s, ",", ".", 1)
+	case reThousands.MatchString(s):
+		s = strings.ReplaceAll(s, ".", "")
+	}
+	return strconv.ParseFloat(s, 32)
+}
+
+func isNumericField(name string) bool {
+	switch name {
+	case "length", "weight", "width", "height", "sale_price", "list_price":
+		return true
+	}
+	return false
+}
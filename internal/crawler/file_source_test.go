@@ -0,0 +1,47 @@
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSourceCSVPrices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "catalogo.csv")
+	csv := "id,display_name,sale_price,list_price\n" +
+		"p1,Split 12000 BTUs,\"1.234,56\",\"1.499,00\"\n" +
+		"p2,Split 9000 BTUs,1899.90,\n" +
+		"p3,Split 18000 BTUs,R$ 3.200,\n" +
+		"p4,Split 24000 BTUs,sob consulta,\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var invalidLines []int
+	src := &FileSource{Path: path, OnInvalid: func(line int, err error) {
+		invalidLines = append(invalidLines, line)
+	}}
+	prices := map[string][2]float32{}
+	if err := src.Crawl(func(p Product) {
+		prices[p.ID] = [2]float32{p.SalePrice, p.ListPrice}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][2]float32{
+		"p1": {1234.56, 1499},
+		"p2": {1899.90, 0},
+		"p3": {3200, 0},
+	}
+	if len(prices) != len(want) {
+		t.Errorf("produtos emitidos = %v, esperava %v", prices, want)
+	}
+	for id, w := range want {
+		if prices[id] != w {
+			t.Errorf("%s: preços = %v, esperava %v", id, prices[id], w)
+		}
+	}
+	if len(invalidLines) != 1 || invalidLines[0] != 5 {
+		t.Errorf("linhas inválidas = %v, esperava [5]", invalidLines)
+	}
+}
@@ -0,0 +1,114 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// HTMLSource é o fallback quando a API JSON do OCC falha ou omite produtos:
+// descobre as páginas de produto pelo sitemap da loja e extrai os dados do HTML.
+// Se IDs estiver preenchido, apenas esses produtos são buscados.
+type HTMLSource struct {
+	BaseURL    string
+	SitemapURL string
+	IDs        []string
+	HTTP       *http.Client
+}
+
+func (s *HTMLSource) Name() string {
+	return "html:" + s.BaseURL
+}
+
+func (s *HTMLSource) Crawl(handler func(Product)) error {
+	sitemapURL := s.SitemapURL
+	if sitemapURL == "" {
+		sitemapURL = s.BaseURL + "/sitemap.xml"
+	}
+
+	client := s.HTTP
+	if client == nil {
+		client = httpClient
+	}
+
+	urls, err := discoverProductURLs(client, sitemapURL)
+	if err != nil {
+		return fmt.Errorf("failed to read sitemap %s: %w", sitemapURL, err)
+	}
+
+	wanted := make(map[string]bool, len(s.IDs))
+	for _, id := range s.IDs {
+		wanted[id] = true
+	}
+
+	var failed int
+	for _, u := range urls {
+		if len(wanted) > 0 && !wanted[ProductIDFromURL(u)] {
+			continue
+		}
+
+		html, err := fetchWith(client, u)
+		if err != nil {
+			log.Printf("[HTML] Erro ao buscar %s: %v", u, err)
+			failed++
+			continue
+		}
+
+		p, err := ParseProduct(u, html)
+		if err != nil || p.ID == "" || p.DisplayName == "" {
+			log.Printf("[HTML] Não foi possível extrair o produto de %s: %v", u, err)
+			failed++
+			continue
+		}
+		handler(p)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d páginas de produto não puderam ser processadas", failed)
+	}
+	return nil
+}
+
+// IDsWithHTMLFallback busca os produtos pela API OCC e, para os IDs que a API
+// omitiu ou não conseguiu devolver, recorre às páginas HTML da loja.
+type IDsWithHTMLFallback struct {
+	Primary  *OCCIDsSource
+	Fallback *HTMLSource
+}
+
+func (s *IDsWithHTMLFallback) Name() string {
+	return s.Primary.Name()
+}
+
+func (s *IDsWithHTMLFallback) Crawl(handler func(Product)) error {
+	seen := make(map[string]bool, len(s.Primary.IDs))
+	primaryErr := s.Primary.Crawl(func(p Product) {
+		seen[p.ID] = true
+		handler(p)
+	})
+
+	var missing []string
+	for _, id := range s.Primary.IDs {
+		if !seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return primaryErr
+	}
+
+	log.Printf("[HTML] %d produtos não retornados pela API, buscando pelas páginas da loja: %v", len(missing), missing)
+	s.Fallback.IDs = missing
+	found := 0
+	err := s.Fallback.Crawl(func(p Product) {
+		found++
+		handler(p)
+	})
+	if err != nil {
+		return err
+	}
+	if found < len(missing) {
+		return fmt.Errorf("%d produtos não encontrados nem na API nem no sitemap", len(missing)-found)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	reInlineSpace = regexp.MustCompile(`[ \t\x{00A0}]+`)
+	reAnySpace    = regexp.MustCompile(`[\s\x{00A0}]+`)
+	reBlankLines  = regexp.MustCompile(`\n{3,}`)
+)
+
+// blockElements quebram linha antes e depois do conteúdo.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "dl": true, "blockquote": true, "pre": true, "hr": true,
+}
+
+// HTMLToText converte as descrições HTML do catálogo em texto preservando a
+// estrutura: parágrafos viram linhas, itens de lista viram "- item", linhas de
+// tabela de especificação viram "Chave: Valor" e scripts/estilos são descartados.
+// As entidades HTML já saem decodificadas.
+func HTMLToText(s string) string {
+	if !strings.ContainsAny(s, "<&") {
+		return strings.TrimSpace(s)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	doc.Find("script, style, noscript, iframe, svg, template").Remove()
+
+	var sb strings.Builder
+	writeNode(&sb, doc.Find("body"), 0)
+	return normalizeText(sb.String())
+}
+
+func writeNode(sb *strings.Builder, sel *goquery.Selection, depth int) {
+	sel.Contents().Each(func(_ int, c *goquery.Selection) {
+		name := goquery.NodeName(c)
+		switch {
+		case name == "#text":
+			// Quebras de linha no código-fonte do HTML são apenas espaço
+			sb.WriteString(reAnySpace.ReplaceAllString(c.Text(), " "))
+		case name == "br":
+			sb.WriteString("\n")
+		case name == "li":
+			sb.WriteString("\n" + strings.Repeat("  ", depth) + "- ")
+			writeNode(sb, c, depth+1)
+		case name == "table":
+			writeTable(sb, c)
+		case name == "dt":
+			sb.WriteString("\n" + cellText(c) + ": ")
+		case name == "dd":
+			sb.WriteString(cellText(c) + "\n")
+		case blockElements[name]:
+			sb.WriteString("\n")
+			writeNode(sb, c, depth)
+			sb.WriteString("\n")
+		default:
+			writeNode(sb, c, depth)
+		}
+	})
+}
+
+// writeTable escreve cada linha da tabela: duas células viram "Chave: Valor",
+// mais células são separadas por " | ".
+func writeTable(sb *strings.Builder, table *goquery.Selection) {
+	sb.WriteString("\n")
+	table.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		var cells []string
+		tr.ChildrenFiltered("th, td").Each(func(_ int, td *goquery.Selection) {
+			if t := cellText(td); t != "" {
+				cells = append(cells, t)
+			}
+		})
+		switch len(cells) {
+		case 0:
+			return
+		case 2:
+			sb.WriteString(strings.TrimSuffix(cells[0], ":") + ": " + cells[1] + "\n")
+		default:
+			sb.WriteString(strings.Join(cells, " | ") + "\n")
+		}
+	})
+	sb.WriteString("\n")
+}
+
+func cellText(sel *goquery.Selection) string {
+	var sb strings.Builder
+	writeNode(&sb, sel, 0)
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// normalizeText compacta espaços em cada linha, mantém as listas sem linhas em
+// branco entre os itens e limita as linhas em branco a uma.
+func normalizeText(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		line = strings.TrimSpace(reInlineSpace.ReplaceAllString(line, " "))
+		if strings.HasPrefix(line, "- ") && indent > 0 {
+			line = strings.Repeat(" ", indent) + line
+		}
+		if line == "-" {
+			line = ""
+		}
+		lines[i] = line
+	}
+
+	isBullet := func(l string) bool { return strings.HasPrefix(strings.TrimSpace(l), "- ") }
+	var out []string
+	for i, line := range lines {
+		if line == "" && len(out) > 0 && isBullet(out[len(out)-1]) {
+			next := i + 1
+			for next < len(lines) && lines[next] == "" {
+				next++
+			}
+			if next < len(lines) && isBullet(lines[next]) {
+				continue
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(reBlankLines.ReplaceAllString(strings.Join(out, "\n"), "\n\n"))
+}
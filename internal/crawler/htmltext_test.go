@@ -0,0 +1,93 @@
+package crawler
+
+import "testing"
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "texto puro",
+			in:   "  Split Inverter 12.000 BTUs  ",
+			want: "Split Inverter 12.000 BTUs",
+		},
+		{
+			name: "parágrafos e listas",
+			in:   "<p>Conforto &amp; economia</p><ul><li>Filtro antibactéria</li><li>Modo <b>Sleep</b></li></ul>",
+			want: "Conforto & economia\n\n- Filtro antibactéria\n- Modo Sleep",
+		},
+		{
+			name: "tabela de especificação",
+			in:   "<table><tr><th>Nível de ruído:</th><td>19 dB(A)</td></tr><tr><td>Gás</td><td>R-32</td></tr></table>",
+			want: "Nível de ruído: 19 dB(A)\nGás: R-32",
+		},
+		{
+			name: "entidades decodificadas uma única vez",
+			in:   "<p>Use &amp;lt;3m de tubulação&nbsp;extra</p>",
+			want: "Use &lt;3m de tubulação extra",
+		},
+		{
+			name: "scripts e estilos descartados",
+			in:   "<style>p{color:red}</style><p>Evaporadora</p><script>track('x')</script>",
+			want: "Evaporadora",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := HTMLToText(tt.in); got != tt.want {
+			t.Errorf("%s: HTMLToText() = %q, esperava %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeDescriptionSpecs(t *testing.T) {
+	occ := OCCProduct{
+		ID:          "p1",
+		DisplayName: "Ar-Condicionado Split Inverter 12.000 BTUs",
+		LongDesc: `<p>Economia: até 70% com a tecnologia inverter.</p>
+<table>
+  <tr><td>Tecnologia</td><td>Inverter</td></tr>
+  <tr><td>Nível de ruído (unidade interna)</td><td>19 dB(A)</td></tr>
+  <tr><td>Selo Procel</td><td>Classe A</td></tr>
+  <tr><td>Tipo de gás</td><td>R410A</td></tr>
+</table>`,
+	}
+
+	p := occ.Normalize("https://loja.example")
+	if p.Ruido != "19 dB(A)" || p.Procel != "Classe A" || p.Gas != "R410A" {
+		t.Fatalf("Normalize() ruído=%q procel=%q gás=%q", p.Ruido, p.Procel, p.Gas)
+	}
+	if p.Tipo != "" {
+		t.Errorf("Tipo = %q, \"Tipo de gás\" não deveria preencher o tipo", p.Tipo)
+	}
+
+	raw := ToRawProduct(p)
+	if raw.RuidoDB != 19 || raw.Procel != "A" || raw.Gas != "R-410A" {
+		t.Errorf("ToRawProduct() ruído=%v procel=%q gás=%q", raw.RuidoDB, raw.Procel, raw.Gas)
+	}
+}
+
+func TestParseSpecs(t *testing.T) {
+	noise := map[string]float32{"19 dB(A)": 19, "52,5 dB": 52.5, "19 a 40 dB": 19, "": 0}
+	for in, want := range noise {
+		if got := ParseNoise(in); got != want {
+			t.Errorf("ParseNoise(%q) = %v, esperava %v", in, got, want)
+		}
+	}
+
+	procel := map[string]string{"A": "A", "Classe a": "A", "Selo Procel B": "B", "Sim": ""}
+	for in, want := range procel {
+		if got := ParseProcel(in); got != want {
+			t.Errorf("ParseProcel(%q) = %q, esperava %q", in, got, want)
+		}
+	}
+
+	gas := map[string]string{"R410A": "R-410A", "R-32": "R-32", "Gás R 22": "R-22", "Ecológico": ""}
+	for in, want := range gas {
+		if got := ParseGas(in); got != want {
+			t.Errorf("ParseGas(%q) = %q, esperava %q", in, got, want)
+		}
+	}
+}
@@ -5,18 +5,33 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 )
 
-var httpClient = &http.Client{
-	Timeout: 60 * time.Second,
+// FrigelarBaseURL é a loja OCC usada por padrão pelo crawler.
+const FrigelarBaseURL = "https://www.frigelar.com.br"
+
+var httpClient = NewHTTPClient(DefaultRequestsPerSecond)
+
+// OCCClient acessa a API de produtos de uma loja Oracle Commerce Cloud.
+type OCCClient struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewOCCClient cria um cliente para a loja OCC em baseURL (ex: https://www.frigelar.com.br).
+func NewOCCClient(baseURL string) *OCCClient {
+	return &OCCClient{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    httpClient,
+	}
 }
 
-func FetchProductsByIDs(ids []string) ([]OCCProduct, error) {
+func (c *OCCClient) FetchProductsByIDs(ids []string) ([]OCCProduct, error) {
 	idList := strings.Join(ids, ",")
 
 	url := fmt.Sprintf(
-		"https://www.frigelar.com.br/ccstoreui/v1/products?productIds=%s&pageSize=%d",
+		"%s/ccstoreui/v1/products?productIds=%s&pageSize=%d",
+		c.BaseURL,
 		idList,
 		len(ids),
 	)
@@ -25,7 +40,7 @@ func FetchProductsByIDs(ids []string) ([]OCCProduct, error) {
 	req.Header.Set("User-Agent", "Mozilla/5.0")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := httpClient.Do(req)
+	resp, err := c.HTTP.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -1,5 +1,7 @@
 package crawler
 
+import "encoding/json"
+
 type OCCProductResponse struct {
 	Items []OCCProduct `json:"items"`
 }
@@ -20,31 +22,146 @@ type OCCLink struct {
 }
 
 type OCCProduct struct {
-	ID                 string  `json:"id"`
-	DisplayName        string  `json:"displayName"`
-	Description        string  `json:"description"`
-	LongDesc           string  `json:"longDescription"`
-	Brand              string  `json:"brand"`
-	Route              string  `json:"route"`
-	Btus               string  `json:"x_quantidadeDeBTUs"`
-	Ciclo              string  `json:"x_ciclo"`
-	Tecnologia         string  `json:"x_tecnologia"`
-	Serpentina         string  `json:"x_serpentina"`
-	Fase               string  `json:"x_fase"`
-	Warranty           string  `json:"x_warranty"`
-	GarantiaCompressor string  `json:"x_garantiaDoCompressor"`
-	Voltagem           string  `json:"x_tension"`
-	Categoria          string  `json:"x_categorias"`
-	CategoriaExtra     string  `json:"x_categoria"`
-	Tipo               string  `json:"type"`
-	Variants           string  `json:"x_variants"`
-	MobileDescription  string  `json:"x_descrioLongMobile"`
-	Url                string  `json:"url"`
-	Slug               string  `json:"seoUrlSlugDerived"`
-	PrimaryImg         string  `json:"primaryFullImageURL"`
-	Length             float32 `json:"length"`
-	Weight             float32 `json:"weight"`
-	Width              float32 `json:"width"`
-	Height             float32 `json:"height"`
-	SalePrice          float32 `json:"salePrice"`
+	ID                 string   `json:"id"`
+	DisplayName        string   `json:"displayName"`
+	Description        string   `json:"description"`
+	LongDesc           string   `json:"longDescription"`
+	Brand              string   `json:"brand"`
+	Route              string   `json:"route"`
+	Btus               string   `json:"x_quantidadeDeBTUs"`
+	Ciclo              string   `json:"x_ciclo"`
+	Tecnologia         string   `json:"x_tecnologia"`
+	Serpentina         string   `json:"x_serpentina"`
+	Fase               string   `json:"x_fase"`
+	Warranty           string   `json:"x_warranty"`
+	GarantiaCompressor string   `json:"x_garantiaDoCompressor"`
+	Voltagem           string   `json:"x_tension"`
+	Categoria          string   `json:"x_categorias"`
+	CategoriaExtra     string   `json:"x_categoria"`
+	Tipo               string   `json:"type"`
+	Variants           string   `json:"x_variants"`
+	MobileDescription  string   `json:"x_descrioLongMobile"`
+	Url                string   `json:"url"`
+	Slug               string   `json:"seoUrlSlugDerived"`
+	PrimaryImg         string   `json:"primaryFullImageURL"`
+	Length             float32  `json:"length"`
+	Weight             float32  `json:"weight"`
+	Width              float32  `json:"width"`
+	Height             float32  `json:"height"`
+	SalePrice          float32  `json:"salePrice"`
+	ListPrice          float32  `json:"listPrice"`
+	LastModified       string   `json:"lastModified"`
+	ChildSKUs          []OCCSKU `json:"childSKUs"`
+
+	// Raw guarda o JSON original do produto, como veio da API
+	Raw json.RawMessage `json:"-"`
+}
+
+// OCCSKU é um SKU do OCC. Nos kits (evaporadora + condensadora) o SKU do kit
+// lista os SKUs dos componentes em bundleLinks.
+type OCCSKU struct {
+	RepositoryID   string          `json:"repositoryId"`
+	DisplayName    string          `json:"displayName"`
+	BundleLinks    []OCCBundleLink `json:"bundleLinks"`
+	ParentProducts []OCCSKURef     `json:"parentProducts"`
+	Length         float32         `json:"length"`
+	Weight         float32         `json:"weight"`
+	Width          float32         `json:"width"`
+	Height         float32         `json:"height"`
+	SalePrice      float32         `json:"salePrice"`
+	ListPrice      float32         `json:"listPrice"`
+}
+
+// OCCBundleLink liga o SKU do kit a um SKU componente.
+type OCCBundleLink struct {
+	Quantity int       `json:"quantity"`
+	Item     OCCSKURef `json:"item"`
+}
+
+// OCCSKURef é a referência resumida a um SKU ou produto.
+type OCCSKURef struct {
+	RepositoryID string `json:"repositoryId"`
+	ID           string `json:"id"`
+	DisplayName  string `json:"displayName"`
+}
+
+func (r OCCSKURef) key() string {
+	if r.RepositoryID != "" {
+		return r.RepositoryID
+	}
+	return r.ID
+}
+
+// components lista os SKUs que compõem o produto, somando quantidades repetidas.
+func (p OCCProduct) components() []Component {
+	var list []Component
+	index := make(map[string]int)
+	for _, sku := range p.ChildSKUs {
+		for _, link := range sku.BundleLinks {
+			id := link.Item.key()
+			if id == "" {
+				continue
+			}
+			qty := link.Quantity
+			if qty <= 0 {
+				qty = 1
+			}
+			if i, ok := index[id]; ok {
+				list[i].Quantity += qty
+				continue
+			}
+			index[id] = len(list)
+			list = append(list, Component{SKU: id, Name: link.Item.DisplayName, Quantity: qty})
+		}
+	}
+	return list
+}
+
+// UnmarshalJSON decodifica o produto preservando o JSON original em Raw.
+func (p *OCCProduct) UnmarshalJSON(b []byte) error {
+	type occProduct OCCProduct
+	var decoded occProduct
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+	*p = OCCProduct(decoded)
+	p.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// Normalize converte o produto OCC para o formato comum do pipeline,
+// resolvendo URL e imagem a partir da loja em baseURL.
+func (p OCCProduct) Normalize(baseURL string) Product {
+	n := Product{
+		ID:              p.ID,
+		Source:          "occ",
+		DisplayName:     p.DisplayName,
+		Description:     p.Description,
+		LongDescription: p.LongDesc,
+		Brand:           p.Brand,
+		Btus:            p.Btus,
+		Ciclo:           p.Ciclo,
+		Tecnologia:      p.Tecnologia,
+		Serpentina:      p.Serpentina,
+		Fase:            p.Fase,
+		Voltagem:        p.Voltagem,
+		Categoria:       p.Categoria,
+		Tipo:            p.Tipo,
+		Variants:        p.Variants,
+		URL:             baseURL + "/" + p.Slug + "/p/" + p.ID,
+		Length:          p.Length,
+		Weight:          p.Weight,
+		Width:           p.Width,
+		Height:          p.Height,
+		SalePrice:       p.SalePrice,
+		ListPrice:       p.ListPrice,
+		LastModified:    p.LastModified,
+		Components:      p.components(),
+		Raw:             p.Raw,
+	}
+	if p.PrimaryImg != "" {
+		n.ImageURL = baseURL + "/" + p.PrimaryImg
+	}
+	applyDescriptionSpecs(&n)
+	return n
 }
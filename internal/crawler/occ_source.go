@@ -0,0 +1,61 @@
+package crawler
+
+import "log"
+
+// OCCCategorySource percorre todas as páginas de uma categoria OCC.
+// StartURL permite retomar a partir de uma página já conhecida e OnPage
+// recebe a próxima página a cada página concluída (checkpoint).
+// No crawl da árvore cada nó é buscado sem as filhas (ExcludeChildren) e
+// os produtos recebem o CategoryPath do nó.
+type OCCCategorySource struct {
+	Client          *OCCClient
+	CategoryID      string
+	CategoryPath    string
+	ExcludeChildren bool
+	StartURL        string
+	OnPage          func(nextURL string)
+}
+
+func (s *OCCCategorySource) Name() string {
+	return "occ:" + s.Client.BaseURL
+}
+
+func (s *OCCCategorySource) Crawl(handler func(Product)) error {
+	startURL := s.StartURL
+	if startURL == "" {
+		startURL = s.Client.CategoryURL(s.CategoryID, !s.ExcludeChildren)
+	}
+	return s.Client.FetchCategoryPages(startURL, func(p OCCProduct) {
+		n := p.Normalize(s.Client.BaseURL)
+		n.CategoryPath = s.CategoryPath
+		s.Client.resolveComponents(&n)
+		handler(n)
+	}, s.OnPage)
+}
+
+// OCCIDsSource busca uma lista fixa de produtos OCC em lotes.
+type OCCIDsSource struct {
+	Client    *OCCClient
+	IDs       []string
+	BatchSize int
+}
+
+func (s *OCCIDsSource) Name() string {
+	return "occ:" + s.Client.BaseURL
+}
+
+func (s *OCCIDsSource) Crawl(handler func(Product)) error {
+	return s.Client.CrawlBatch(s.IDs, s.BatchSize, func(p OCCProduct) {
+		n := p.Normalize(s.Client.BaseURL)
+		s.Client.resolveComponents(&n)
+		handler(n)
+	})
+}
+
+// resolveComponents busca os componentes dos kits; uma falha não impede o
+// produto de ser salvo, apenas mantém a composição anterior.
+func (c *OCCClient) resolveComponents(p *Product) {
+	if err := c.ResolveComponents(p); err != nil {
+		log.Printf("Erro ao resolver %v", err)
+	}
+}
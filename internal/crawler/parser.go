@@ -1,21 +1,175 @@
 package crawler
 
 import (
+	"encoding/json"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
-func ParseProduct(html string) (string, error) {
+var (
+	reProductPath = regexp.MustCompile(`/p/([^/?#]+)`)
+	reSpecNumber  = regexp.MustCompile(`\d[\d.]*`)
+)
+
+// ProductIDFromURL extrai o ID do produto de uma URL de página de produto
+// (ex: https://www.frigelar.com.br/ar-condicionado-split/p/kit11106).
+func ProductIDFromURL(u string) string {
+	if m := reProductPath.FindStringSubmatch(u); len(m) > 1 {
+		return m[1]
+	}
+	return ""
+}
+
+// ParseProduct extrai da página HTML de um produto os mesmos campos que a API
+// OCC fornece: título, tabela de especificações, preço e imagem.
+func ParseProduct(pageURL, html string) (Product, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
 	if err != nil {
-		return "", err
+		return Product{}, err
+	}
+
+	p := Product{
+		ID:     ProductIDFromURL(pageURL),
+		Source: "html",
+		URL:    pageURL,
+	}
+
+	p.DisplayName = strings.TrimSpace(doc.Find("h1").First().Text())
+	if p.DisplayName == "" {
+		p.DisplayName = metaContent(doc, `meta[property="og:title"]`)
 	}
 
-	var content []string
-	doc.Find("h1, h2, p, li").Each(func(_ int, s *goquery.Selection) {
-		content = append(content, strings.TrimSpace(s.Text()))
+	p.Description = metaContent(doc, `meta[name="description"]`)
+	if desc := strings.TrimSpace(doc.Find(`[itemprop="description"]`).First().Text()); desc != "" {
+		p.Description = desc
+	}
+
+	p.ImageURL = metaContent(doc, `meta[property="og:image"]`)
+	if p.ImageURL == "" {
+		p.ImageURL, _ = doc.Find(`img[itemprop="image"]`).First().Attr("src")
+	}
+
+	price := metaContent(doc, `meta[itemprop="price"]`)
+	if price == "" {
+		price = metaContent(doc, `meta[property="product:price:amount"]`)
+	}
+	if price == "" {
+		price = strings.TrimSpace(doc.Find(`[itemprop="price"]`).First().Text())
+	}
+	p.SalePrice = parseBRL(price)
+
+	p.Brand = strings.TrimSpace(doc.Find(`[itemprop="brand"]`).First().Text())
+
+	// Tabela de especificações: linhas "Chave | Valor"
+	var specs []string
+	doc.Find("table tr").Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("th, td")
+		if cells.Length() < 2 {
+			return
+		}
+		key := strings.TrimSpace(cells.Eq(0).Text())
+		value := strings.TrimSpace(cells.Eq(1).Text())
+		if key == "" || value == "" {
+			return
+		}
+		specs = append(specs, key+": "+value)
+		applySpec(&p, key, value)
 	})
+	if len(specs) > 0 {
+		p.LongDescription = strings.TrimSpace(p.Description + "\n\n" + strings.Join(specs, "\n"))
+	}
+
+	p.Raw, _ = json.Marshal(p)
+	return p, nil
+}
+
+// applySpec preenche os campos estruturados a partir de uma linha da tabela de especificações.
+func applySpec(p *Product, key, value string) {
+	k := strings.ToLower(key)
+	switch {
+	case strings.Contains(k, "marca") && p.Brand == "":
+		p.Brand = value
+	case strings.Contains(k, "btu") || strings.Contains(k, "capacidade"):
+		if n := reSpecNumber.FindString(value); n != "" && p.Btus == "" {
+			p.Btus = n
+		}
+	case strings.Contains(k, "ciclo"):
+		p.Ciclo = value
+	case strings.Contains(k, "tecnologia"):
+		p.Tecnologia = value
+	case strings.Contains(k, "voltagem") || strings.Contains(k, "tensão") || strings.Contains(k, "tensao"):
+		p.Voltagem = value
+	case strings.Contains(k, "fase"):
+		p.Fase = value
+	case strings.Contains(k, "serpentina"):
+		p.Serpentina = value
+	case strings.Contains(k, "ruído") || strings.Contains(k, "ruido"):
+		p.Ruido = value
+	case strings.Contains(k, "procel"):
+		p.Procel = value
+	case strings.Contains(k, "gás") || strings.Contains(k, "gas") || strings.Contains(k, "refrigerante"):
+		p.Gas = value
+	// Depois de gás: "Tipo de gás" não é o tipo do aparelho
+	case strings.Contains(k, "tipo"):
+		p.Tipo = value
+	}
+}
+
+// applyDescriptionSpecs preenche os campos ainda vazios com as linhas
+// "Chave: Valor" que HTMLToText extrai das tabelas de especificação do
+// longDescription (ruído, selo Procel, gás...). Os campos vindos da origem
+// têm prioridade.
+func applyDescriptionSpecs(p *Product) {
+	if p.LongDescription == "" {
+		return
+	}
+	var found Product
+	for _, line := range strings.Split(HTMLToText(p.LongDescription), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		// Itens de lista e frases com dois-pontos não são linhas de tabela
+		if !ok || key == "" || value == "" || len(key) > 40 || strings.HasPrefix(key, "-") {
+			continue
+		}
+		applySpec(&found, key, value)
+	}
 
-	return strings.Join(content, "\n"), nil
+	fill := func(dst *string, v string) {
+		if *dst == "" {
+			*dst = v
+		}
+	}
+	fill(&p.Brand, found.Brand)
+	fill(&p.Btus, found.Btus)
+	fill(&p.Ciclo, found.Ciclo)
+	fill(&p.Tecnologia, found.Tecnologia)
+	fill(&p.Voltagem, found.Voltagem)
+	fill(&p.Fase, found.Fase)
+	fill(&p.Serpentina, found.Serpentina)
+	fill(&p.Ruido, found.Ruido)
+	fill(&p.Procel, found.Procel)
+	fill(&p.Gas, found.Gas)
+}
+
+func metaContent(doc *goquery.Document, selector string) string {
+	v, _ := doc.Find(selector).First().Attr("content")
+	return strings.TrimSpace(v)
+}
+
+// parseBRL converte "R$ 2.499,90" ou "2499.90" para float.
+func parseBRL(s string) float32 {
+	s = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "R$"))
+	s = strings.ReplaceAll(s, " ", "")
+	if strings.Contains(s, ",") {
+		s = strings.ReplaceAll(s, ".", "")
+		s = strings.ReplaceAll(s, ",", ".")
+	}
+	v, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return 0
+	}
+	return float32(v)
 }
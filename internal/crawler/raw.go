@@ -0,0 +1,138 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"iaprj/internal/model"
+)
+
+var (
+	reBTUNumber   = regexp.MustCompile(`\d{1,3}(?:\.\d{3})+|\d+`)
+	reACPrefix    = regexp.MustCompile(`(?i)^Ar[- ]Condicionado\s+`)
+	reNoiseNumber = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+	reProcelClass = regexp.MustCompile(`\b([A-G])\b`)
+	reGasName     = regexp.MustCompile(`\bR-?\s?(\d{2,3}[A-Z]?)\b`)
+)
+
+// ToRawProduct prepara o produto para o RawRepository: o texto de ProductToText
+// é usado apenas para embeddings, enquanto as colunas estruturadas (marca, BTUs,
+// preço, dimensões...) vêm direto dos campos do produto.
+func ToRawProduct(p Product) model.RawProduct {
+	return model.RawProduct{
+		ID:           uuid.New().String(),
+		ProdutoID:    p.ID,
+		Source:       p.Source,
+		CategoryPath: p.CategoryPath,
+		SourceURL:    p.URL,
+		ImageURL:     p.ImageURL,
+		Brand:        strings.TrimSpace(p.Brand),
+		Btus:         ParseBTU(p.Btus),
+		Ciclo:        strings.TrimSpace(p.Ciclo),
+		Voltagem:     strings.TrimSpace(p.Voltagem),
+		Tecnologia:   strings.TrimSpace(p.Tecnologia),
+		RuidoDB:      ParseNoise(p.Ruido),
+		Procel:       ParseProcel(p.Procel),
+		Gas:          ParseGas(p.Gas),
+		Type:         productType(p),
+		Content:      ProductToText(&p),
+		SalePrice:    p.SalePrice,
+		ListPrice:    p.ListPrice,
+		Length:       p.Length,
+		Weight:       p.Weight,
+		Width:        p.Width,
+		Height:       p.Height,
+		LastModified: p.LastModified,
+		RawJSON:      p.Raw,
+		TextTemplate: ActiveTextTemplateVersion(),
+	}
+}
+
+// ParseBTU converte a capacidade do OCC para inteiro. Aceita "12000", "12.000"
+// e faixas como "9.000/12.000", das quais usa o primeiro valor.
+func ParseBTU(s string) int {
+	m := reBTUNumber.FindString(s)
+	if m == "" {
+		return 0
+	}
+	v, _ := strconv.Atoi(strings.ReplaceAll(m, ".", ""))
+	return v
+}
+
+// ParseNoise converte o nível de ruído ("19 dB(A)", "52,5 dB") para decibéis,
+// usando o primeiro valor de faixas como "19 a 40 dB".
+func ParseNoise(s string) float32 {
+	m := reNoiseNumber.FindString(s)
+	if m == "" {
+		return 0
+	}
+	v, err := strconv.ParseFloat(strings.Replace(m, ",", ".", 1), 32)
+	if err != nil {
+		return 0
+	}
+	return float32(v)
+}
+
+// ParseProcel extrai a classe do selo Procel ("A", "Classe A", "a") ou "".
+func ParseProcel(s string) string {
+	if m := reProcelClass.FindStringSubmatch(strings.ToUpper(s)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// ParseGas normaliza o gás refrigerante ("R410A", "R-32", "Gás R 22") para "R-410A".
+func ParseGas(s string) string {
+	if m := reGasName.FindStringSubmatch(strings.ToUpper(s)); m != nil {
+		return "R-" + m[1]
+	}
+	return ""
+}
+
+// productType deriva o tipo do aparelho da categoria ("Ar-Condicionado Split" -> "Split").
+// Para outras famílias (cortinas de ar, peças...) o tipo é a própria categoria.
+func productType(p Product) string {
+	if c := strings.TrimSpace(p.Categoria); c != "" {
+		return strings.TrimSpace(reACPrefix.ReplaceAllString(c, ""))
+	}
+	return strings.TrimSpace(p.Tipo)
+}
+
+// DecodeRaw reconstrói o produto normalizado a partir do registro original
+// guardado em product_raw_knowledge.raw_json. sourceURL é usado para descobrir
+// a loja de origem dos produtos OCC.
+func DecodeRaw(source, sourceURL string, raw []byte) (Product, error) {
+	switch source {
+	case "occ":
+		var o OCCProduct
+		if err := json.Unmarshal(raw, &o); err != nil {
+			return Product{}, fmt.Errorf("invalid OCC product: %w", err)
+		}
+		return o.Normalize(storeBaseURL(sourceURL)), nil
+	default:
+		var p Product
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return Product{}, fmt.Errorf("invalid %s product: %w", source, err)
+		}
+		if p.Source == "" {
+			p.Source = source
+		}
+		p.Raw = append(json.RawMessage(nil), raw...)
+		return p, nil
+	}
+}
+
+// storeBaseURL extrai "https://host" de uma URL de produto.
+func storeBaseURL(sourceURL string) string {
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Host == "" {
+		return FrigelarBaseURL
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type sitemapIndex struct {
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapURLSet struct {
+	URLs []sitemapLoc `xml:"url"`
+}
+
+type sitemapLoc struct {
+	Loc string `xml:"loc"`
+}
+
+// DiscoverProductURLs lê o sitemap da loja (seguindo índices de sitemaps) e
+// devolve as URLs de páginas de produto ("/p/<id>").
+func DiscoverProductURLs(sitemapURL string) ([]string, error) {
+	return discoverProductURLs(httpClient, sitemapURL)
+}
+
+func discoverProductURLs(client *http.Client, sitemapURL string) ([]string, error) {
+	var urls []string
+	seen := make(map[string]bool)
+
+	var walk func(u string) error
+	walk = func(u string) error {
+		if seen[u] {
+			return nil
+		}
+		seen[u] = true
+
+		body, err := fetchSitemap(client, u)
+		if err != nil {
+			return err
+		}
+
+		var index sitemapIndex
+		if err := xml.Unmarshal(body, &index); err == nil && len(index.Sitemaps) > 0 {
+			for _, s := range index.Sitemaps {
+				if err := walk(strings.TrimSpace(s.Loc)); err != nil {
+					// Um sitemap filho com problema não invalida os demais
+					log.Printf("[Sitemap] Erro ao ler %s: %v", s.Loc, err)
+				}
+			}
+			return nil
+		}
+
+		var set sitemapURLSet
+		if err := xml.Unmarshal(body, &set); err != nil {
+			return err
+		}
+		for _, entry := range set.URLs {
+			loc := strings.TrimSpace(entry.Loc)
+			if ProductIDFromURL(loc) != "" {
+				urls = append(urls, loc)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(sitemapURL); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+func fetchSitemap(client *http.Client, u string) ([]byte, error) {
+	body, err := fetchWith(client, u)
+	if err != nil {
+		return nil, err
+	}
+	b := []byte(body)
+
+	// Sitemaps grandes costumam ser publicados como .xml.gz
+	if len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return io.ReadAll(gz)
+	}
+	return b, nil
+}
@@ -0,0 +1,172 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// SnapshotMetaSuffix é o sufixo do arquivo de metadados gravado ao lado do
+// snapshot (ex: catalog.ndjson -> catalog.ndjson.meta).
+const SnapshotMetaSuffix = ".meta"
+
+// SnapshotMeta é uma linha do arquivo de metadados: o contexto do crawl
+// necessário para normalizar de novo o registro da mesma linha do snapshot.
+type SnapshotMeta struct {
+	ID           string      `json:"id"`
+	Source       string      `json:"source"`
+	URL          string      `json:"url,omitempty"`
+	CategoryPath string      `json:"category_path,omitempty"`
+	Components   []Component `json:"components,omitempty"`
+}
+
+// SnapshotWriter grava os registros originais (ex: o JSON do OCCProduct, sem
+// alterações) em um arquivo NDJSON, e os metadados do crawl em um arquivo
+// separado com SnapshotMetaSuffix, linha a linha. Pode ser usado por vários
+// workers ao mesmo tempo.
+type SnapshotWriter struct {
+	mu      sync.Mutex
+	files   []*os.File
+	w       *bufio.Writer
+	metaW   *bufio.Writer
+	metaEnc *json.Encoder
+}
+
+func NewSnapshotWriter(path string) (*SnapshotWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := os.Create(path + SnapshotMetaSuffix)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	metaW := bufio.NewWriter(meta)
+	return &SnapshotWriter{
+		files:   []*os.File{f, meta},
+		w:       bufio.NewWriter(f),
+		metaW:   metaW,
+		metaEnc: json.NewEncoder(metaW),
+	}, nil
+}
+
+// Write adiciona o produto ao snapshot. Produtos sem registro original são
+// gravados com sua forma normalizada.
+func (s *SnapshotWriter) Write(p Product) error {
+	var line bytes.Buffer
+	if len(p.Raw) > 0 {
+		// Uma linha por registro; o conteúdo não muda
+		if err := json.Compact(&line, p.Raw); err != nil {
+			return err
+		}
+	} else {
+		raw, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		line.Write(raw)
+	}
+	line.WriteByte('\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(line.Bytes()); err != nil {
+		return err
+	}
+	return s.metaEnc.Encode(SnapshotMeta{
+		ID:           p.ID,
+		Source:       p.Source,
+		URL:          p.URL,
+		CategoryPath: p.CategoryPath,
+		Components:   p.Components,
+	})
+}
+
+func (s *SnapshotWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.w.Flush()
+	if metaErr := s.metaW.Flush(); err == nil {
+		err = metaErr
+	}
+	for _, f := range s.files {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// SnapshotSource reproduz um snapshot gravado com SnapshotWriter, sem rede.
+// Sem o arquivo de metadados, as linhas são lidas como OCCProduct da loja padrão.
+type SnapshotSource struct {
+	Path string
+}
+
+func (s *SnapshotSource) Name() string {
+	return "snapshot:" + s.Path
+}
+
+func (s *SnapshotSource) Crawl(handler func(Product)) error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var meta *bufio.Scanner
+	if mf, err := os.Open(s.Path + SnapshotMetaSuffix); err == nil {
+		defer mf.Close()
+		meta = bufio.NewScanner(mf)
+		meta.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		m := SnapshotMeta{Source: "occ"}
+		if meta != nil {
+			if !meta.Scan() {
+				return fmt.Errorf("%s:%d: metadados ausentes em %s", s.Path, line, s.Path+SnapshotMetaSuffix)
+			}
+			if err := json.Unmarshal(meta.Bytes(), &m); err != nil {
+				return fmt.Errorf("%s%s:%d: %w", s.Path, SnapshotMetaSuffix, line, err)
+			}
+		}
+		p, err := DecodeRaw(m.Source, m.URL, scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", s.Path, line, err)
+		}
+		if meta != nil && p.ID != m.ID {
+			return fmt.Errorf("%s:%d: metadados do produto %s não correspondem ao registro %s", s.Path, line, m.ID, p.ID)
+		}
+		if p.CategoryPath == "" {
+			p.CategoryPath = m.CategoryPath
+		}
+		// Componentes já resolvidos no crawl original (dimensões vêm de outra chamada)
+		if len(m.Components) > 0 {
+			p.Components = m.Components
+		}
+		if p.ID != "" {
+			handler(p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if meta != nil {
+		return meta.Err()
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package crawler
+
+import "encoding/json"
+
+// Product é a representação normalizada de um item de catálogo, independente
+// da origem (OCC, arquivo, etc.). É o que o pipeline transforma em texto e
+// grava no RawRepository.
+type Product struct {
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	DisplayName     string      `json:"display_name"`
+	Description     string      `json:"description"`
+	LongDescription string      `json:"long_description"`
+	Brand           string      `json:"brand"`
+	Btus            string      `json:"btus"`
+	Ciclo           string      `json:"ciclo"`
+	Tecnologia      string      `json:"tecnologia"`
+	Serpentina      string      `json:"serpentina"`
+	Fase            string      `json:"fase"`
+	Voltagem        string      `json:"voltagem"`
+	Categoria       string      `json:"categoria"`
+	Tipo            string      `json:"tipo"`
+	Ruido           string      `json:"ruido,omitempty"`
+	Procel          string      `json:"procel,omitempty"`
+	Gas             string      `json:"gas,omitempty"`
+	CategoryPath    string      `json:"category_path"`
+	Variants        string      `json:"variants"`
+	Components      []Component `json:"components,omitempty"`
+	URL             string      `json:"url"`
+	ImageURL        string      `json:"image_url"`
+	Length          float32     `json:"length"`
+	Weight          float32     `json:"weight"`
+	Width           float32     `json:"width"`
+	Height          float32     `json:"height"`
+	SalePrice       float32     `json:"sale_price"`
+	ListPrice       float32     `json:"list_price"`
+	LastModified    string      `json:"last_modified"`
+
+	// Raw é o registro original na origem (JSON do OCC, linha do arquivo...),
+	// guardado para reprocessar o catálogo sem novo crawl.
+	Raw json.RawMessage `json:"-"`
+}
+
+// CatalogSource é uma origem de catálogo capaz de alimentar o pipeline.
+// Crawl chama handler para cada produto encontrado, já normalizado.
+type CatalogSource interface {
+	Name() string
+	Crawl(handler func(Product)) error
+}
@@ -1,84 +1,154 @@
 package crawler
 
 import (
+	"bytes"
+	"embed"
 	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
+	"text/template"
 )
 
-func stripHTML(s string) string {
-	re := regexp.MustCompile(`<[^>]*>`)
-	return re.ReplaceAllString(s, "")
-}
+// DefaultTextTemplateVersion é o layout usado quando nenhuma versão é escolhida.
+const DefaultTextTemplateVersion = "v1"
 
-func ProductToText(p *OCCProduct) string {
-	var sb strings.Builder
+// defaultTemplateName é usado quando não existe template para a categoria do produto.
+const defaultTemplateName = "default"
 
-	// 1. Título principal - a informação mais importante para o embedding.
-	sb.WriteString(p.DisplayName + "\n\n")
+//go:embed templates
+var embeddedTemplates embed.FS
 
-	// 2. Descrições de marketing (MOVIDO PARA CIMA)
-	if p.LongDesc != "" {
-		sb.WriteString("Descrição Detalhada:\n" + stripHTML(p.LongDesc) + "\n\n")
-	} else if p.Description != "" { // Fallback para descrição curta
-		sb.WriteString("Descrição:\n" + stripHTML(p.Description) + "\n\n")
-	}
+var reSlugSeparator = regexp.MustCompile(`[^a-z0-9]+`)
 
-	// 2. Bloco de especificações técnicas chave - para busca assertiva
-	sb.WriteString("--- Especificações Técnicas ---\n")
-	if p.Brand != "" {
-		sb.WriteString("Marca: " + p.Brand + "\n")
-	}
-	if p.Btus != "" {
-		sb.WriteString("Capacidade: " + p.Btus + " BTUs\n")
-	}
-	if p.Ciclo != "" {
-		sb.WriteString("Ciclo: " + p.Ciclo + "\n")
-	}
-	if p.Tecnologia != "" {
-		sb.WriteString("Tecnologia: " + p.Tecnologia + "\n")
-	}
-	if p.Voltagem != "" {
-		sb.WriteString("Voltagem: " + p.Voltagem + "\n")
-	}
-	if p.Fase != "" {
-		sb.WriteString("Fase: " + p.Fase + "\n")
-	}
-	if p.Serpentina != "" {
-		sb.WriteString("Serpentina: " + p.Serpentina + "\n")
-	}
-	if p.Categoria != "" {
-		sb.WriteString("Categoria: " + p.Categoria + "\n")
-	}
-	if p.Tipo != "" {
-		sb.WriteString("Tipo: " + p.Tipo + "\n")
-	}
+var accentReplacer = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "é", "e", "ê", "e", "í", "i",
+	"ó", "o", "ô", "o", "õ", "o", "ú", "u", "ü", "u", "ç", "c",
+)
 
-	if p.Length > 0 {
-		sb.WriteString("Comprimento: " + fmt.Sprintf("%g", p.Length) + "\n")
-	}
-	if p.Width > 0 {
-		sb.WriteString("Largura: " + fmt.Sprintf("%g", p.Width) + "\n")
+var templateFuncs = template.FuncMap{
+	"text": HTMLToText,
+	"num":  func(v float32) string { return fmt.Sprintf("%g", v) },
+}
+
+// TextTemplates renderiza o documento de embedding de cada produto a partir dos
+// arquivos <versão>/<categoria>.tmpl. A categoria é o slug de um segmento do
+// category_path (ex: "departamentos/pecas" -> pecas.tmpl), com fallback para
+// default.tmpl.
+type TextTemplates struct {
+	Version   string
+	templates map[string]*template.Template
+}
+
+// LoadTextTemplates carrega os templates da versão. Com dir vazio usa os
+// templates embutidos no binário; caso contrário lê dir/<versão>/*.tmpl.
+func LoadTextTemplates(version, dir string) (*TextTemplates, error) {
+	var fsys fs.FS = embeddedTemplates
+	root := path.Join("templates", version)
+	if dir != "" {
+		fsys = os.DirFS(dir)
+		root = version
 	}
-	if p.Height > 0 {
-		sb.WriteString("Altura: " + fmt.Sprintf("%g", p.Height) + "\n")
+
+	files, err := fs.Glob(fsys, root+"/*.tmpl")
+	if err != nil {
+		return nil, err
 	}
-	if p.Weight > 0 {
-		sb.WriteString("Peso: " + fmt.Sprintf("%g", p.Weight) + "\n")
+	if len(files) == 0 {
+		return nil, fmt.Errorf("nenhum template encontrado para a versão %s", version)
 	}
-	sb.WriteString("-----------------------------\n\n")
-	// 3. Preço de Venda
-	if p.SalePrice > 0 {
-		sb.WriteString("Preço de Venda: " + fmt.Sprintf("%g", p.SalePrice) + "\n")
+
+	t := &TextTemplates{Version: version, templates: make(map[string]*template.Template)}
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".tmpl")
+		tmpl, err := template.New(name).Funcs(templateFuncs).ParseFS(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = tmpl.Lookup(path.Base(file))
+		// Executa com um produto vazio para pegar campos inexistentes já no carregamento
+		if err := tmpl.Execute(&bytes.Buffer{}, &Product{}); err != nil {
+			return nil, fmt.Errorf("template %s: %w", file, err)
+		}
+		t.templates[name] = tmpl
+	}
+	if t.templates[defaultTemplateName] == nil {
+		return nil, fmt.Errorf("a versão %s não tem %s.tmpl", version, defaultTemplateName)
+	}
+	return t, nil
+}
+
+// Render gera o documento de embedding do produto.
+func (t *TextTemplates) Render(p *Product) (string, error) {
+	var buf bytes.Buffer
+	if err := t.lookup(p).Execute(&buf, p); err != nil {
+		return "", err
 	}
+	return buf.String(), nil
+}
 
-	// 4. Metadados para extração posterior (não para embedding direto, mas precisa estar no texto)
-	p.Url = "https://www.frigelar.com.br/" + p.Slug + "/p/" + p.ID
-	sb.WriteString("URL: " + p.Url + "\n")
+// lookup escolhe o template da categoria mais específica do produto.
+func (t *TextTemplates) lookup(p *Product) *template.Template {
+	segments := strings.Split(p.CategoryPath, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if tmpl := t.templates[slugify(segments[i])]; tmpl != nil {
+			return tmpl
+		}
+	}
+	if tmpl := t.templates[slugify(p.Categoria)]; tmpl != nil {
+		return tmpl
+	}
+	return t.templates[defaultTemplateName]
+}
+
+func slugify(s string) string {
+	s = accentReplacer.Replace(strings.ToLower(strings.TrimSpace(s)))
+	return strings.Trim(reSlugSeparator.ReplaceAllString(s, "-"), "-")
+}
 
-	if p.PrimaryImg != "" {
-		sb.WriteString("Imagem Principal: https://www.frigelar.com.br/" + p.PrimaryImg + "\n")
+var (
+	activeTemplatesMu sync.RWMutex
+	activeTemplates   *TextTemplates
+	defaultTemplates  *TextTemplates
+)
+
+func init() {
+	var err error
+	defaultTemplates, err = LoadTextTemplates(DefaultTextTemplateVersion, "")
+	if err != nil {
+		panic(err)
 	}
+	activeTemplates = defaultTemplates
+}
+
+// UseTextTemplates troca o layout usado por ProductToText (flag -template-version).
+func UseTextTemplates(t *TextTemplates) {
+	activeTemplatesMu.Lock()
+	defer activeTemplatesMu.Unlock()
+	activeTemplates = t
+}
 
-	return sb.String()
+// ActiveTextTemplateVersion retorna a versão do layout em uso.
+func ActiveTextTemplateVersion() string {
+	activeTemplatesMu.RLock()
+	defer activeTemplatesMu.RUnlock()
+	return activeTemplates.Version
+}
+
+// ProductToText renderiza o documento de embedding com os templates ativos.
+func ProductToText(p *Product) string {
+	activeTemplatesMu.RLock()
+	t := activeTemplates
+	activeTemplatesMu.RUnlock()
+
+	text, err := t.Render(p)
+	if err != nil {
+		log.Printf("Erro ao renderizar template %s do produto %s, usando %s: %v", t.Version, p.ID, DefaultTextTemplateVersion, err)
+		text, _ = defaultTemplates.Render(p)
+	}
+	return text
 }
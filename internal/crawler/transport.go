@@ -0,0 +1,224 @@
+package crawler
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"iaprj/internal/observability"
+)
+
+const (
+	// DefaultRequestsPerSecond limita o volume de chamadas para a loja.
+	DefaultRequestsPerSecond = 4.0
+
+	defaultMaxRetries = 5
+	defaultBaseDelay  = 500 * time.Millisecond
+	defaultMaxDelay   = 30 * time.Second
+	defaultTimeout    = 60 * time.Second
+)
+
+// RetryTransport é um http.RoundTripper que limita as requisições por segundo
+// e refaz chamadas que falharam por erro de rede, 429 ou 5xx, usando backoff
+// exponencial com jitter e respeitando o header Retry-After. Timeout limita
+// cada tentativa, incluindo a leitura do corpo da resposta (0 = sem limite).
+type RetryTransport struct {
+	Base       http.RoundTripper
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	Timeout    time.Duration
+
+	limiter *rateLimiter
+}
+
+// NewRetryTransport cria um transport limitado a rps requisições por segundo (0 = sem limite).
+func NewRetryTransport(rps float64) *RetryTransport {
+	return &RetryTransport{
+		Base: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: 60 * time.Second,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		MaxRetries: defaultMaxRetries,
+		BaseDelay:  defaultBaseDelay,
+		MaxDelay:   defaultMaxDelay,
+		Timeout:    defaultTimeout,
+		limiter:    newRateLimiter(rps),
+	}
+}
+
+// NewHTTPClient cria o cliente HTTP usado pelo crawler. O timeout fica por
+// tentativa no transport (RetryTransport.Timeout), já que as esperas entre
+// retries podem somar mais que o timeout de uma única chamada.
+func NewHTTPClient(rps float64) *http.Client {
+	return &http.Client{Transport: NewRetryTransport(rps)}
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		attemptReq, cancel, err := t.newAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := t.Base.RoundTrip(attemptReq)
+		observeRequest(resp, err, time.Since(start))
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+		if attempt >= t.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			if err != nil {
+				cancel()
+				return resp, err
+			}
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		delay := t.backoff(attempt)
+		if err != nil {
+			log.Printf("[Crawler] Erro de rede em %s (tentativa %d/%d): %v", req.URL, attempt+1, t.MaxRetries, err)
+		} else {
+			// Retry-After é respeitado, mas sem esperar mais que MaxDelay
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = min(retryAfter, t.MaxDelay)
+			}
+			log.Printf("[Crawler] Status %d em %s (tentativa %d/%d), aguardando %s", resp.StatusCode, req.URL, attempt+1, t.MaxRetries, delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// newAttempt clona a requisição para uma tentativa, com o prazo de Timeout e,
+// a partir da segunda tentativa, um corpo novo obtido de GetBody; a requisição
+// do chamador nunca é alterada. O cancel só deve ser chamado depois que o corpo
+// da resposta for lido.
+func (t *RetryTransport) newAttempt(req *http.Request, attempt int) (*http.Request, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if t.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+	}
+	attemptReq := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		attemptReq.Body = body
+	}
+	return attemptReq, cancel, nil
+}
+
+// cancelOnClose libera o prazo da tentativa quando o corpo da resposta é fechado.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// backoff calcula a espera da tentativa com "full jitter": um valor aleatório
+// entre zero e BaseDelay * 2^attempt, limitado a MaxDelay.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	max := t.BaseDelay << attempt
+	if max <= 0 || max > t.MaxDelay {
+		max = t.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// observeRequest registra status e latência de cada tentativa nas métricas do crawler.
+func observeRequest(resp *http.Response, err error, elapsed time.Duration) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	observability.CrawlerHTTPRequestsTotal.WithLabelValues(code).Inc()
+	observability.CrawlerHTTPDuration.Observe(elapsed.Seconds())
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter aceita tanto segundos ("120") quanto uma data HTTP.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// rateLimiter distribui as requisições em intervalos fixos entre todas as goroutines.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rps float64) *rateLimiter {
+	if rps <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OCCCategory é um nó da árvore de categorias devolvida pelo endpoint de collections.
+type OCCCategory struct {
+	ID           string        `json:"id"`
+	RepositoryID string        `json:"repositoryId"`
+	DisplayName  string        `json:"displayName"`
+	Children     []OCCCategory `json:"childCategories"`
+}
+
+// CategoryNode é uma categoria a ser crawleada, com o caminho desde a raiz
+// (ex: "ar-condicionado/split/split-inverter").
+type CategoryNode struct {
+	ID   string
+	Name string
+	Path string
+}
+
+// FetchCategoryTree busca a categoria rootID e todas as suas filhas.
+func (c *OCCClient) FetchCategoryTree(rootID string) (*OCCCategory, error) {
+	u := fmt.Sprintf("%s/ccstoreui/v1/collections/%s?maxLevel=10&expand=childCategories", c.BaseURL, url.PathEscape(rootID))
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCC status %d for %s", resp.StatusCode, u)
+	}
+
+	var root OCCCategory
+	if err := json.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to decode response from %s: %w", u, err)
+	}
+	return &root, nil
+}
+
+// Flatten lista a categoria e todas as descendentes, cada uma com seu caminho.
+func (c *OCCCategory) Flatten() []CategoryNode {
+	var nodes []CategoryNode
+	var walk func(cat *OCCCategory, parent string)
+	walk = func(cat *OCCCategory, parent string) {
+		id := cat.categoryID()
+		if id == "" {
+			return
+		}
+		path := id
+		if parent != "" {
+			path = parent + "/" + id
+		}
+		nodes = append(nodes, CategoryNode{ID: id, Name: cat.DisplayName, Path: path})
+		for i := range cat.Children {
+			walk(&cat.Children[i], path)
+		}
+	}
+	walk(c, "")
+	return nodes
+}
+
+func (c *OCCCategory) categoryID() string {
+	if c.RepositoryID != "" {
+		return strings.TrimSpace(c.RepositoryID)
+	}
+	return strings.TrimSpace(c.ID)
+}
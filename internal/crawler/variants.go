@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Variant é um produto irmão do mesmo modelo (ex: a versão 220V de um split 110V).
+type Variant struct {
+	ProductID string
+	Label     string // valor que diferencia a variante (ex: "220V", "Quente/Frio")
+	Attribute string // voltagem, ciclo ou variante
+}
+
+var (
+	variantIDKeys    = []string{"productId", "produtoId", "id", "repositoryId", "skuId", "sku"}
+	variantLabelKeys = []string{"x_tension", "voltagem", "tensao", "tensão", "voltage", "x_ciclo", "ciclo", "label", "value", "name", "displayName"}
+)
+
+// ParseVariants interpreta o JSON de x_variants. O OCC não tem um formato único
+// para esse campo, então são aceitos:
+//
+//	{"110V": "kit1", "220V": "kit2"}
+//	{"x_tension": {"110V": "kit1", "220V": "kit2"}}
+//	[{"productId": "kit1", "x_tension": "110V"}, ...]
+//
+// O próprio produto (productID) é removido do resultado.
+func ParseVariants(productID, raw string) []Variant {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(raw), &data); err != nil {
+		return nil
+	}
+
+	var variants []Variant
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			switch inner := value.(type) {
+			case string:
+				variants = append(variants, Variant{ProductID: inner, Label: key, Attribute: variantAttribute("", key)})
+			case map[string]interface{}:
+				for label, id := range inner {
+					if s, ok := id.(string); ok {
+						variants = append(variants, Variant{ProductID: s, Label: label, Attribute: variantAttribute(key, label)})
+					}
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id := firstString(obj, variantIDKeys)
+			if id == "" {
+				continue
+			}
+			labelKey, label := firstKeyString(obj, variantLabelKeys)
+			variants = append(variants, Variant{ProductID: id, Label: label, Attribute: variantAttribute(labelKey, label)})
+		}
+	}
+
+	// Remove o próprio produto e entradas sem ID
+	result := variants[:0]
+	seen := map[string]bool{productID: true}
+	for _, v := range variants {
+		v.ProductID = strings.TrimSpace(v.ProductID)
+		if v.ProductID == "" || seen[v.ProductID] {
+			continue
+		}
+		seen[v.ProductID] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+// variantAttribute identifica qual atributo diferencia a variante, pela chave ou pelo valor.
+func variantAttribute(key, label string) string {
+	k := strings.ToLower(key)
+	l := strings.ToLower(label)
+	switch {
+	case strings.Contains(k, "tension") || strings.Contains(k, "volt") || strings.Contains(k, "tens"):
+		return "voltagem"
+	case strings.Contains(k, "ciclo"):
+		return "ciclo"
+	case strings.Contains(l, "110") || strings.Contains(l, "127") || strings.Contains(l, "220") || strings.Contains(l, "380"):
+		return "voltagem"
+	case strings.Contains(l, "frio") || strings.Contains(l, "quente"):
+		return "ciclo"
+	}
+	return "variante"
+}
+
+func firstString(obj map[string]interface{}, keys []string) string {
+	_, v := firstKeyString(obj, keys)
+	return v
+}
+
+func firstKeyString(obj map[string]interface{}, keys []string) (string, string) {
+	for _, k := range keys {
+		if s, ok := obj[k].(string); ok && s != "" {
+			return k, s
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations contém o DDL idempotente do projeto, aplicado em ordem por Migrate.
+// Cada instrução precisa poder ser executada várias vezes sem efeito colateral.
+var migrations = []string{
+	// Tabelas base (antes criadas manualmente)
+	`CREATE EXTENSION IF NOT EXISTS vector`,
+	`CREATE TABLE IF NOT EXISTS product_raw_knowledge (
+		id          TEXT PRIMARY KEY,
+		produto_id  TEXT NOT NULL UNIQUE,
+		source_url  TEXT,
+		raw_content TEXT,
+		sync_status CHAR(1) NOT NULL DEFAULT 'S'
+	)`,
+	`CREATE TABLE IF NOT EXISTS product_knowledge (
+		id         UUID PRIMARY KEY,
+		produto_id TEXT NOT NULL,
+		source_url TEXT,
+		image_url  TEXT,
+		brand      TEXT,
+		btus       INT NOT NULL DEFAULT 0,
+		ciclo      TEXT,
+		voltagem   TEXT,
+		tecnologia TEXT,
+		type       TEXT,
+		content    TEXT,
+		embedding  vector(1536),
+		sale_price REAL NOT NULL DEFAULT 0,
+		length     REAL NOT NULL DEFAULT 0,
+		weight     REAL NOT NULL DEFAULT 0,
+		width      REAL NOT NULL DEFAULT 0,
+		height     REAL NOT NULL DEFAULT 0,
+		stock      INT NOT NULL DEFAULT 1,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+
+	// Crawling incremental: hash do texto gerado e dados de versão do OCC
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS content_hash TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS last_modified TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS sale_price REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS list_price REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS crawled_at TIMESTAMPTZ`,
+
+	// Origem do produto (CatalogSource que o gerou)
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'occ'`,
+
+	// Histórico de execuções do crawler (checkpoint para -resume)
+	`CREATE TABLE IF NOT EXISTS crawl_runs (
+		id              BIGSERIAL PRIMARY KEY,
+		source          TEXT NOT NULL,
+		category        TEXT NOT NULL DEFAULT '',
+		status          TEXT NOT NULL,
+		started_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+		finished_at     TIMESTAMPTZ,
+		next_url        TEXT,
+		new_count       INT NOT NULL DEFAULT 0,
+		changed_count   INT NOT NULL DEFAULT 0,
+		unchanged_count INT NOT NULL DEFAULT 0,
+		failed_count    INT NOT NULL DEFAULT 0,
+		errors          TEXT
+	)`,
+	`CREATE INDEX IF NOT EXISTS crawl_runs_source_category_idx ON crawl_runs (source, category, started_at DESC)`,
+
+	// Produtos que saíram do catálogo (tombstone)
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS category_id TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS inactive_since TIMESTAMPTZ`,
+	`ALTER TABLE product_knowledge ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT true`,
+
+	// Variantes do mesmo modelo (voltagem, ciclo) extraídas de x_variants
+	`CREATE TABLE IF NOT EXISTS product_variants (
+		produto_id         TEXT NOT NULL,
+		variant_produto_id TEXT NOT NULL,
+		label              TEXT NOT NULL DEFAULT '',
+		attribute          TEXT NOT NULL DEFAULT 'variante',
+		PRIMARY KEY (produto_id, variant_produto_id)
+	)`,
+
+	// Caminho da categoria de cada produto (crawl da árvore de categorias)
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS category_path TEXT`,
+	`ALTER TABLE product_knowledge ADD COLUMN IF NOT EXISTS category_path TEXT`,
+
+	// Registro original da origem e colunas tipadas derivadas dele (sem regex sobre o texto)
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS raw_json JSONB`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS image_url TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS brand TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS btus INT NOT NULL DEFAULT 0`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS ciclo TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS voltagem TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS tecnologia TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS type TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS length REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS weight REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS width REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS height REAL NOT NULL DEFAULT 0`,
+
+	// Histórico de preços (uma linha por observação do crawler)
+	`CREATE TABLE IF NOT EXISTS product_price_history (
+		id          BIGSERIAL PRIMARY KEY,
+		produto_id  TEXT NOT NULL,
+		sale_price  REAL NOT NULL DEFAULT 0,
+		list_price  REAL NOT NULL DEFAULT 0,
+		in_stock    BOOLEAN,
+		source      TEXT NOT NULL,
+		observed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_price_history_produto_idx ON product_price_history (produto_id, observed_at DESC)`,
+
+	// Versão do template de texto que gerou raw_content
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS text_template TEXT`,
+
+	// Componentes dos kits (evaporadora/condensadora) com dimensões reais
+	`CREATE TABLE IF NOT EXISTS product_components (
+		produto_id           TEXT NOT NULL,
+		component_sku        TEXT NOT NULL,
+		component_produto_id TEXT,
+		name                 TEXT NOT NULL DEFAULT '',
+		role                 TEXT NOT NULL DEFAULT 'componente',
+		quantity             INT NOT NULL DEFAULT 1,
+		length               REAL NOT NULL DEFAULT 0,
+		weight               REAL NOT NULL DEFAULT 0,
+		width                REAL NOT NULL DEFAULT 0,
+		height               REAL NOT NULL DEFAULT 0,
+		sale_price           REAL NOT NULL DEFAULT 0,
+		PRIMARY KEY (produto_id, component_sku)
+	)`,
+
+	// Grupos de anúncios do mesmo modelo (job de dedup); group_id é o produto canônico
+	`CREATE TABLE IF NOT EXISTS product_groups (
+		produto_id TEXT PRIMARY KEY,
+		group_id   TEXT NOT NULL,
+		canonical  BOOLEAN NOT NULL DEFAULT false,
+		reason     TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_groups_group_idx ON product_groups (group_id)`,
+
+	// Chunks identificados por (produto_id, chunk_index). Linhas antigas, sem hash,
+	// são numeradas pela ordem de criação e o produto volta para a fila de
+	// embeddings, para que ReplaceChunks troque o conjunto inteiro mesmo que o
+	// texto do produto não mude.
+	`ALTER TABLE product_knowledge ADD COLUMN IF NOT EXISTS chunk_index INT`,
+	`ALTER TABLE product_knowledge ADD COLUMN IF NOT EXISTS content_hash TEXT`,
+	`UPDATE product_knowledge k SET chunk_index = n.rn - 1
+	FROM (
+		SELECT id, row_number() OVER (PARTITION BY produto_id ORDER BY created_at, id) AS rn
+		FROM product_knowledge
+	) n
+	WHERE k.id = n.id AND k.chunk_index IS NULL`,
+	`ALTER TABLE product_knowledge ALTER COLUMN chunk_index SET NOT NULL`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS product_knowledge_chunk_idx ON product_knowledge (produto_id, chunk_index)`,
+	`UPDATE product_raw_knowledge SET sync_status = 'S'
+	WHERE sync_status <> 'S' AND produto_id IN (SELECT produto_id FROM product_knowledge WHERE content_hash IS NULL)`,
+
+	// Cache de embeddings por modelo e sha256 do texto (vector sem dimensão fixa,
+	// para aceitar modelos diferentes)
+	`CREATE TABLE IF NOT EXISTS embedding_cache (
+		model        TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		embedding    vector NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (model, content_hash)
+	)`,
+
+	// Modelo que gerou cada vetor e registro dos índices de embeddings
+	// (o ativo serve as buscas; o "building" é a tabela sombra de um re-index)
+	`ALTER TABLE product_knowledge ADD COLUMN IF NOT EXISTS embedding_model TEXT NOT NULL DEFAULT 'text-embedding-3-small'`,
+	`CREATE TABLE IF NOT EXISTS embedding_indexes (
+		model        TEXT NOT NULL,
+		dimensions   INT NOT NULL,
+		status       TEXT NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		activated_at TIMESTAMPTZ,
+		PRIMARY KEY (model, dimensions)
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS embedding_indexes_status_idx ON embedding_indexes (status) WHERE status IN ('active', 'building')`,
+	`INSERT INTO embedding_indexes (model, dimensions, status, activated_at)
+	SELECT 'text-embedding-3-small', 1536, 'active', now()
+	WHERE NOT EXISTS (SELECT 1 FROM embedding_indexes)`,
+
+	// Fila de falhas de embedding (reprocessada com cmd/embeddings -retry-failed)
+	`CREATE TABLE IF NOT EXISTS embedding_failures (
+		produto_id      TEXT PRIMARY KEY,
+		reason          TEXT NOT NULL,
+		error           TEXT,
+		attempts        INT NOT NULL DEFAULT 1,
+		first_failed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		last_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+
+	// Todas as categorias em que o produto aparece. product_raw_knowledge guarda a
+	// principal, que só muda quando o produto deixa de ser listado nela.
+	`CREATE TABLE IF NOT EXISTS product_categories (
+		produto_id    TEXT NOT NULL,
+		category_id   TEXT NOT NULL,
+		category_path TEXT NOT NULL DEFAULT '',
+		seen_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (produto_id, category_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_categories_category_idx ON product_categories (category_id)`,
+	`INSERT INTO product_categories (produto_id, category_id, category_path, seen_at)
+	SELECT produto_id, category_id, COALESCE(category_path, ''), COALESCE(crawled_at, now())
+	FROM product_raw_knowledge
+	WHERE category_id IS NOT NULL
+	ON CONFLICT DO NOTHING`,
+
+	// Especificações extraídas das tabelas do longDescription
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS ruido_db REAL NOT NULL DEFAULT 0`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS procel TEXT`,
+	`ALTER TABLE product_raw_knowledge ADD COLUMN IF NOT EXISTS gas TEXT`,
+
+	// Histórico de disponibilidade (uma linha por verificação do job de estoque),
+	// separado do histórico de preços
+	`CREATE TABLE IF NOT EXISTS product_availability_history (
+		id          BIGSERIAL PRIMARY KEY,
+		produto_id  TEXT NOT NULL,
+		in_stock    BOOLEAN NOT NULL,
+		source      TEXT NOT NULL,
+		observed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE INDEX IF NOT EXISTS product_availability_history_produto_idx ON product_availability_history (produto_id, observed_at DESC)`,
+	// Move as linhas de preço 0 que o job de estoque gravava em product_price_history
+	`WITH moved AS (
+		DELETE FROM product_price_history WHERE source = 'stock' RETURNING produto_id, in_stock, observed_at
+	)
+	INSERT INTO product_availability_history (produto_id, in_stock, source, observed_at)
+	SELECT produto_id, in_stock, 'stock', observed_at FROM moved WHERE in_stock IS NOT NULL`,
+}
+
+// Migrate aplica o schema esperado pelos jobs e pelo chat.
+func Migrate(conn *sql.DB) error {
+	for i, stmt := range migrations {
+		if _, err := conn.Exec(stmt); err != nil {
+			return fmt.Errorf("migration %d: %w", i, err)
+		}
+	}
+	return nil
+}
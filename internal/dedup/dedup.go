@@ -0,0 +1,193 @@
+package dedup
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"iaprj/internal/model"
+)
+
+// DefaultSimilarity é a similaridade de cosseno mínima para considerar dois
+// produtos do mesmo bloco (marca, BTUs, voltagem, ciclo e tipo) o mesmo modelo.
+const DefaultSimilarity = 0.97
+
+var (
+	reToken    = regexp.MustCompile(`[A-Za-z0-9][A-Za-z0-9\-/.]*[A-Za-z0-9]`)
+	reNotAlnum = regexp.MustCompile(`[^A-Z0-9]`)
+	reLetter   = regexp.MustCompile(`[A-Z]`)
+	reDigit    = regexp.MustCompile(`[0-9]`)
+	// Unidades e combinações delas ("12000BTUS", "220V60HZ", "220V1F") não são modelo
+	reUnitToken = regexp.MustCompile(`^(\d+(BTUS?H?|V|W|HZ|F|KG|CM|MM|M2))+$`)
+	reGasToken  = regexp.MustCompile(`^R\d{2,3}A?$`)
+	reKitToken  = regexp.MustCompile(`^KIT\d+$`)
+)
+
+// ModelCodes extrai do nome os códigos de modelo do fabricante
+// (ex: "S3-Q12JA31A" -> "S3Q12JA31A"), ignorando unidades, gases e IDs de kit.
+func ModelCodes(name string) []string {
+	var codes []string
+	seen := make(map[string]bool)
+	for _, tok := range reToken.FindAllString(name, -1) {
+		code := reNotAlnum.ReplaceAllString(strings.ToUpper(tok), "")
+		if len(code) < 6 || !reLetter.MatchString(code) || !reDigit.MatchString(code) {
+			continue
+		}
+		if reUnitToken.MatchString(code) || reGasToken.MatchString(code) || reKitToken.MatchString(code) {
+			continue
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// Cluster agrupa os candidatos que são o mesmo modelo. Só produtos do mesmo
+// bloco (marca, BTUs, voltagem, ciclo e tipo) são comparados, então as versões
+// 110V/220V de um modelo continuam separadas. Dentro do bloco dois produtos são
+// ligados quando compartilham um código de modelo ou quando os embeddings têm
+// similaridade >= minSimilarity. Retorna apenas os grupos com mais de um produto.
+func Cluster(candidates []model.DedupCandidate, minSimilarity float64) []model.ProductGroupMember {
+	uf := newUnionFind(len(candidates))
+	reasons := make(map[int]string)
+
+	blocks := make(map[string][]int)
+	for i, c := range candidates {
+		if c.Brand == "" {
+			continue // sem marca não há como afirmar que é o mesmo modelo
+		}
+		blocks[blockKey(c)] = append(blocks[blockKey(c)], i)
+	}
+
+	for _, idx := range blocks {
+		codeOwner := make(map[string]int)
+		for _, i := range idx {
+			for _, code := range ModelCodes(candidates[i].Name) {
+				if j, ok := codeOwner[code]; ok {
+					if uf.union(i, j) {
+						reasons[i], reasons[j] = "modelo", "modelo"
+					}
+				} else {
+					codeOwner[code] = i
+				}
+			}
+		}
+
+		for a := 0; a < len(idx); a++ {
+			for b := a + 1; b < len(idx); b++ {
+				i, j := idx[a], idx[b]
+				if uf.find(i) == uf.find(j) {
+					continue
+				}
+				if cosine(candidates[i].Embedding, candidates[j].Embedding) >= minSimilarity {
+					uf.union(i, j)
+					if reasons[i] == "" {
+						reasons[i] = "similaridade"
+					}
+					if reasons[j] == "" {
+						reasons[j] = "similaridade"
+					}
+				}
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range candidates {
+		root := uf.find(i)
+		groups[root] = append(groups[root], i)
+	}
+
+	var members []model.ProductGroupMember
+	for _, idx := range groups {
+		if len(idx) < 2 {
+			continue
+		}
+		canonical := pickCanonical(candidates, idx)
+		for _, i := range idx {
+			members = append(members, model.ProductGroupMember{
+				ProdutoID: candidates[i].ProdutoID,
+				GroupID:   candidates[canonical].ProdutoID,
+				Canonical: i == canonical,
+				Reason:    reasons[i],
+			})
+		}
+	}
+	sort.Slice(members, func(a, b int) bool {
+		if members[a].GroupID != members[b].GroupID {
+			return members[a].GroupID < members[b].GroupID
+		}
+		return members[a].ProdutoID < members[b].ProdutoID
+	})
+	return members
+}
+
+func blockKey(c model.DedupCandidate) string {
+	norm := func(s string) string { return strings.ToLower(strings.TrimSpace(s)) }
+	return strings.Join([]string{norm(c.Brand), strconv.Itoa(c.Btus), norm(c.Voltagem), norm(c.Ciclo), norm(c.Type)}, "|")
+}
+
+// pickCanonical escolhe o anúncio com menor preço (e menor ID no empate).
+func pickCanonical(candidates []model.DedupCandidate, idx []int) int {
+	best := idx[0]
+	for _, i := range idx[1:] {
+		c, b := candidates[i], candidates[best]
+		switch {
+		case c.SalePrice > 0 && (b.SalePrice <= 0 || c.SalePrice < b.SalePrice):
+			best = i
+		case c.SalePrice == b.SalePrice && c.ProdutoID < b.ProdutoID:
+			best = i
+		}
+	}
+	return best
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{parent: make([]int, n)}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return uf
+}
+
+func (u *unionFind) find(i int) int {
+	for u.parent[i] != i {
+		u.parent[i] = u.parent[u.parent[i]]
+		i = u.parent[i]
+	}
+	return i
+}
+
+// union liga os dois conjuntos e indica se eles eram diferentes.
+func (u *unionFind) union(a, b int) bool {
+	ra, rb := u.find(a), u.find(b)
+	if ra == rb {
+		return false
+	}
+	u.parent[rb] = ra
+	return true
+}
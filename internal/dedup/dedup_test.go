@@ -0,0 +1,99 @@
+package dedup
+
+import (
+	"reflect"
+	"testing"
+
+	"iaprj/internal/model"
+)
+
+func TestModelCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Ar-Condicionado Split Inverter Midea S3-Q12JA31A 12.000 BTUs Frio 220V", []string{"S3Q12JA31A"}},
+		{"Split Samsung AR12BVHZCWK 12000 BTUs 220V/60Hz", []string{"AR12BVHZCWK"}},
+		{"Condensadora LG 18000BTUs 220V/1F Gás R-410A", nil},
+		{"Split Hi-Wall 9000 BTU/h 127/220V 60Hz", nil},
+		{"Kit kit11106 Evaporadora 42AFVCI12S5 + Condensadora 38TVCA12M5", []string{"42AFVCI12S5", "38TVCA12M5"}},
+		{"Evaporadora 42AFVCI12S5 / 42AFVCI12S5", []string{"42AFVCI12S5"}},
+	}
+
+	for _, tt := range tests {
+		if got := ModelCodes(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ModelCodes(%q) = %v, esperava %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCluster(t *testing.T) {
+	samsung := func(id, name string, price float32, embedding ...float32) model.DedupCandidate {
+		return model.DedupCandidate{
+			ProdutoID: id, Name: name, Brand: "Samsung", Btus: 12000, Voltagem: "220V",
+			Ciclo: "Frio", Type: "split", SalePrice: price, Embedding: embedding,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		candidates []model.DedupCandidate
+		want       []model.ProductGroupMember
+	}{
+		{
+			name: "mesmo código de modelo",
+			candidates: []model.DedupCandidate{
+				samsung("p1", "Split Samsung AR12BVHZCWK 12000 BTUs", 2500, 1, 0),
+				samsung("p2", "Ar-Condicionado AR12BVHZCWK Samsung WindFree", 2300, 0, 1),
+			},
+			want: []model.ProductGroupMember{
+				{ProdutoID: "p1", GroupID: "p2", Reason: "modelo"},
+				{ProdutoID: "p2", GroupID: "p2", Canonical: true, Reason: "modelo"},
+			},
+		},
+		{
+			name: "voltagem e frequência não ligam modelos diferentes",
+			candidates: []model.DedupCandidate{
+				samsung("p1", "Split Samsung AR12BVHZCWK 220V/60Hz", 2500, 1, 0),
+				samsung("p2", "Split Samsung AR12TVHZDWK 220V/60Hz", 2300, 0, 1),
+			},
+			want: nil,
+		},
+		{
+			name: "embeddings quase iguais",
+			candidates: []model.DedupCandidate{
+				samsung("p1", "Split Samsung 12000 BTUs", 0, 1, 0.01),
+				samsung("p2", "Ar Condicionado Samsung 12.000 BTUs", 2300, 1, 0.02),
+			},
+			want: []model.ProductGroupMember{
+				{ProdutoID: "p1", GroupID: "p2", Reason: "similaridade"},
+				{ProdutoID: "p2", GroupID: "p2", Canonical: true, Reason: "similaridade"},
+			},
+		},
+		{
+			name: "blocos diferentes nunca se juntam",
+			candidates: []model.DedupCandidate{
+				samsung("p1", "Split Samsung AR12BVHZCWK", 2500, 1, 0),
+				{ProdutoID: "p2", Name: "Split Samsung AR12BVHZCWK", Brand: "Samsung", Btus: 12000,
+					Voltagem: "127V", Ciclo: "Frio", Type: "split", SalePrice: 2300, Embedding: []float32{1, 0}},
+			},
+			want: nil,
+		},
+		{
+			name: "sem marca não agrupa",
+			candidates: []model.DedupCandidate{
+				{ProdutoID: "p1", Name: "Split AR12BVHZCWK", Embedding: []float32{1, 0}},
+				{ProdutoID: "p2", Name: "Split AR12BVHZCWK", Embedding: []float32{1, 0}},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Cluster(tt.candidates, DefaultSimilarity); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cluster() = %+v, esperava %+v", got, tt.want)
+			}
+		})
+	}
+}
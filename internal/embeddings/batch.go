@@ -0,0 +1,92 @@
+package embeddings
+
+import (
+	"context"
+
+	"iaprj/internal/model"
+)
+
+// Limites de uma requisição de embeddings da OpenAI.
+const (
+	MaxBatchInputs = 2048
+	MaxBatchTokens = 300000
+)
+
+// productChunks são os chunks de um produto que vão juntos no mesmo lote.
+type productChunks struct {
+	product model.RawProduct
+	chunks  []Chunk
+}
+
+// embeddingBatch agrupa chunks de vários produtos em uma única requisição.
+// Os chunks de um produto nunca são divididos entre lotes, então cada lote
+// pode ser salvo e marcado como processado de forma independente.
+type embeddingBatch struct {
+	items  []productChunks
+	inputs int
+	tokens int
+}
+
+// newBatch monta um lote com os produtos informados.
+func newBatch(items []productChunks) *embeddingBatch {
+	b := &embeddingBatch{items: items}
+	for _, item := range items {
+		b.inputs += len(item.chunks)
+		for _, c := range item.chunks {
+			b.tokens += c.Tokens
+		}
+	}
+	return b
+}
+
+func (b *embeddingBatch) texts() []string {
+	texts := make([]string, 0, b.inputs)
+	for _, item := range b.items {
+		for _, c := range item.chunks {
+			texts = append(texts, c.Text)
+		}
+	}
+	return texts
+}
+
+// buildBatches divide os produtos em lotes respeitando o número de entradas
+// e de tokens por requisição. Para de enviar lotes quando ctx é cancelado.
+func buildBatches(ctx context.Context, products []model.RawProduct, chunker *Chunker, maxInputs, maxTokens int, out chan<- *embeddingBatch) {
+	defer close(out)
+
+	send := func(b *embeddingBatch) bool {
+		// Com um worker livre e ctx cancelado o select escolheria ao acaso
+		if ctx.Err() != nil {
+			return false
+		}
+		select {
+		case out <- b:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	batch := &embeddingBatch{}
+	for _, p := range products {
+		// Produtos sem conteúdo seguem no lote apenas para serem marcados como processados
+		chunks := chunker.Split(p.Content)
+		tokens := 0
+		for _, c := range chunks {
+			tokens += c.Tokens
+		}
+
+		if len(batch.items) > 0 && (batch.inputs+len(chunks) > maxInputs || batch.tokens+tokens > maxTokens) {
+			if !send(batch) {
+				return
+			}
+			batch = &embeddingBatch{}
+		}
+		batch.items = append(batch.items, productChunks{product: p, chunks: chunks})
+		batch.inputs += len(chunks)
+		batch.tokens += tokens
+	}
+	if len(batch.items) > 0 {
+		send(batch)
+	}
+}
@@ -0,0 +1,76 @@
+package embeddings
+
+import (
+	"context"
+	"log"
+
+	"iaprj/internal/model"
+	"iaprj/internal/repository"
+)
+
+// CachedEmbedder consulta o embedding_cache antes de chamar o Embedder
+// original e grava os vetores novos. Falhas no cache não interrompem a
+// geração: no pior caso o texto é enviado à API como antes.
+type CachedEmbedder struct {
+	Embedder Embedder
+	Cache    *repository.EmbeddingCacheRepository
+}
+
+func NewCachedEmbedder(embedder Embedder, cache *repository.EmbeddingCacheRepository) *CachedEmbedder {
+	return &CachedEmbedder{Embedder: embedder, Cache: cache}
+}
+
+func (e *CachedEmbedder) Model() string {
+	return e.Embedder.Model()
+}
+
+func (e *CachedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddingModel := e.Model()
+
+	hashes := make([]string, len(texts))
+	for i, t := range texts {
+		hashes[i] = model.ContentHash(t)
+	}
+
+	cached, err := e.Cache.Get(embeddingModel, hashes)
+	if err != nil {
+		log.Printf("[EmbeddingCache] Erro ao consultar cache: %v", err)
+		cached = map[string][]float32{}
+	}
+
+	// Textos repetidos no mesmo lote são enviados uma única vez
+	var missing []string
+	var missingHashes []string
+	queued := make(map[string]bool)
+	for i, h := range hashes {
+		if _, ok := cached[h]; ok || queued[h] {
+			continue
+		}
+		queued[h] = true
+		missing = append(missing, texts[i])
+		missingHashes = append(missingHashes, h)
+	}
+
+	if len(missing) > 0 {
+		vectors, err := e.Embedder.Embed(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		fresh := make(map[string][]float32, len(missing))
+		for i, h := range missingHashes {
+			fresh[h] = vectors[i]
+			cached[h] = vectors[i]
+		}
+		if err := e.Cache.Put(embeddingModel, fresh); err != nil {
+			log.Printf("[EmbeddingCache] Erro ao gravar cache: %v", err)
+		}
+	}
+
+	log.Printf("[EmbeddingCache] %d textos, %d do cache, %d gerados", len(texts), len(texts)-len(missing), len(missing))
+
+	vectors := make([][]float32, len(texts))
+	for i, h := range hashes {
+		vectors[i] = cached[h]
+	}
+	return vectors, nil
+}
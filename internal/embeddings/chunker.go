@@ -1,13 +1,271 @@
 package embeddings
 
-func Chunk(text string, size int) []string {
-	var chunks []string
-	for len(text) > size {
-		chunks = append(chunks, text[:size])
-		text = text[size:]
+import (
+	"regexp"
+	"strings"
+)
+
+// Limites padrão dos chunks, em tokens do modelo de embedding.
+const (
+	DefaultChunkMaxTokens     = 400
+	DefaultChunkOverlapTokens = 40
+)
+
+var (
+	// "--- Especificações Técnicas ---" abre um bloco que termina em "-----...".
+	reSectionStart = regexp.MustCompile(`^---\s.*\s---$`)
+	reSectionEnd   = regexp.MustCompile(`^-{5,}$`)
+	// Cabeçalhos de descrição emitidos pelo ProductToText ("Descrição Detalhada:")
+	reHeading = regexp.MustCompile(`^Descrição[^:]*:$`)
+)
+
+// Chunk é um pedaço do documento do produto. Text é o que vai para o modelo de
+// embedding (título do produto + sobreposição com o chunk anterior + Content);
+// Content é apenas o trecho novo, gravado para reconstruir o documento.
+type Chunk struct {
+	Index   int
+	Text    string
+	Content string
+	Tokens  int
+}
+
+// Chunker divide os documentos gerados por ProductToText respeitando as seções
+// (título, descrição, especificações técnicas) e um orçamento de tokens.
+type Chunker struct {
+	Tokenizer     Tokenizer
+	MaxTokens     int
+	OverlapTokens int
+}
+
+func NewChunker(tokenizer Tokenizer, maxTokens, overlapTokens int) *Chunker {
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkMaxTokens
 	}
-	if len(text) > 0 {
-		chunks = append(chunks, text)
+	if overlapTokens < 0 || overlapTokens >= maxTokens {
+		overlapTokens = 0
+	}
+	return &Chunker{Tokenizer: tokenizer, MaxTokens: maxTokens, OverlapTokens: overlapTokens}
+}
+
+// Split divide o texto em chunks de até MaxTokens tokens (contando o título e a
+// sobreposição). Seções inteiras são mantidas juntas sempre que cabem; seções
+// grandes são quebradas por linha e, em último caso, por palavra, nunca no meio
+// de um caractere UTF-8.
+func (c *Chunker) Split(text string) []Chunk {
+	text = strings.TrimSpace(strings.ToValidUTF8(text, ""))
+	if text == "" {
+		return nil
+	}
+
+	sections := splitSections(text)
+	title := sections[0]
+	if c.count(title) > c.MaxTokens/4 {
+		title = "" // título enorme é tratado como uma seção comum
+	} else {
+		sections = sections[1:]
+	}
+
+	// Orçamento do conteúdo novo de cada chunk, descontando título e sobreposição
+	budget := c.MaxTokens - c.OverlapTokens
+	if title != "" {
+		budget -= c.count(title) + 1
+	}
+	if budget < c.MaxTokens/4 {
+		budget = c.MaxTokens / 4
+	}
+
+	var pieces []string
+	for _, s := range sections {
+		pieces = append(pieces, c.fit(s, budget)...)
+	}
+
+	var contents []string
+	var current []string
+	currentTokens := 0
+	for _, p := range pieces {
+		t := c.count(p)
+		if len(current) > 0 && currentTokens+t+1 > budget {
+			contents = append(contents, strings.Join(current, "\n\n"))
+			current, currentTokens = nil, 0
+		}
+		current = append(current, p)
+		currentTokens += t + 1
+	}
+	if len(current) > 0 {
+		contents = append(contents, strings.Join(current, "\n\n"))
+	}
+
+	// O título abre o primeiro chunk e é repetido no texto de embedding dos demais
+	if title != "" {
+		if len(contents) == 0 {
+			contents = []string{title}
+		} else {
+			contents[0] = title + "\n\n" + contents[0]
+		}
+	}
+
+	chunks := make([]Chunk, len(contents))
+	for i, content := range contents {
+		var parts []string
+		if i > 0 {
+			if title != "" {
+				parts = append(parts, title)
+			}
+			if overlap := c.tail(contents[i-1], c.OverlapTokens); overlap != "" {
+				parts = append(parts, overlap)
+			}
+		}
+		parts = append(parts, content)
+		embedText := strings.Join(parts, "\n\n")
+		chunks[i] = Chunk{Index: i, Text: embedText, Content: content, Tokens: c.count(embedText)}
 	}
 	return chunks
 }
+
+func (c *Chunker) count(s string) int {
+	return c.Tokenizer.Count(s)
+}
+
+// fit quebra uma seção maior que o orçamento em linhas e, se preciso, em palavras.
+func (c *Chunker) fit(section string, budget int) []string {
+	if c.count(section) <= budget {
+		return []string{section}
+	}
+
+	var out []string
+	var current []string
+	currentTokens := 0
+	flush := func() {
+		if len(current) > 0 {
+			out = append(out, strings.Join(current, "\n"))
+			current, currentTokens = nil, 0
+		}
+	}
+
+	for _, line := range strings.Split(section, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		for _, part := range c.splitWords(line, budget) {
+			t := c.count(part)
+			if len(current) > 0 && currentTokens+t+1 > budget {
+				flush()
+			}
+			current = append(current, part)
+			currentTokens += t + 1
+		}
+	}
+	flush()
+	return out
+}
+
+// splitWords quebra uma linha longa demais entre palavras. Uma palavra que
+// sozinha passa do orçamento é quebrada entre caracteres (splitRunes).
+func (c *Chunker) splitWords(line string, budget int) []string {
+	if c.count(line) <= budget {
+		return []string{line}
+	}
+
+	var out []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			out = append(out, strings.Join(current, " "))
+			current = nil
+		}
+	}
+	for _, w := range strings.Fields(line) {
+		if c.count(w) > budget {
+			flush()
+			out = append(out, c.splitRunes(w, budget)...)
+			continue
+		}
+		candidate := strings.Join(append(current, w), " ")
+		if len(current) > 0 && c.count(candidate) > budget {
+			flush()
+		}
+		current = append(current, w)
+	}
+	flush()
+	return out
+}
+
+// splitRunes quebra uma palavra em pedaços de até budget tokens, sempre entre
+// caracteres UTF-8, buscando o maior prefixo que cabe no orçamento.
+func (c *Chunker) splitRunes(word string, budget int) []string {
+	var out []string
+	runes := []rune(word)
+	for len(runes) > 0 {
+		// Ao menos um caractere por pedaço, mesmo que ele sozinho passe do orçamento
+		lo, hi := 1, len(runes)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if c.count(string(runes[:mid])) <= budget {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		out = append(out, string(runes[:lo]))
+		runes = runes[lo:]
+	}
+	return out
+}
+
+// tail retorna as últimas palavras de s que somam até n tokens.
+func (c *Chunker) tail(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	words := strings.Fields(s)
+	start := len(words)
+	for start > 0 && c.count(strings.Join(words[start-1:], " ")) <= n {
+		start--
+	}
+	return strings.Join(words[start:], " ")
+}
+
+// splitSections separa o documento nos blocos emitidos por ProductToText: o
+// título (primeiro parágrafo), cada descrição com seu cabeçalho, o bloco de
+// especificações técnicas e os demais parágrafos.
+func splitSections(text string) []string {
+	var sections []string
+	var current []string
+	inBlock := false
+	flush := func() {
+		s := strings.TrimSpace(strings.Join(current, "\n"))
+		if s != "" {
+			sections = append(sections, s)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inBlock:
+			current = append(current, line)
+			if reSectionEnd.MatchString(trimmed) {
+				inBlock = false
+				flush()
+			}
+		case reSectionStart.MatchString(trimmed):
+			flush()
+			current = append(current, line)
+			inBlock = true
+		case reHeading.MatchString(trimmed):
+			flush()
+			current = append(current, line)
+		case trimmed == "" && len(sections) == 0:
+			flush() // fim do título
+		default:
+			current = append(current, line)
+		}
+	}
+	flush()
+
+	if len(sections) == 0 {
+		return []string{text}
+	}
+	return sections
+}
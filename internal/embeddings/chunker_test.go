@@ -0,0 +1,304 @@
+package embeddings
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"iaprj/internal/crawler"
+)
+
+const (
+	descricaoSplit = `O ar-condicionado split inverter economiza até 60% de energia em comparação
+com modelos convencionais. A função turbo atinge a temperatura desejada rapidamente e o modo
+sleep ajusta a refrigeração durante a noite para um sono mais confortável.
+
+O filtro antibactéria remove partículas de poeira, ácaros e fungos, melhorando a qualidade do
+ar. A serpentina de cobre garante maior durabilidade, eficiência na troca de calor e facilidade
+de manutenção. Ideal para quartos e escritórios de até 15 m².`
+
+	// Parágrafo único, sem quebras de linha, maior que o orçamento de um chunk
+	descricaoLonga = `Condicionador de ar com tecnologia inversora de última geração, ventilação
+silenciosa, operação em função refrigeração e aquecimento, compressor rotativo de alta
+eficiência, proteção anticorrosão na unidade externa, controle remoto com visor iluminado,
+timer de 24 horas, reinício automático após queda de energia, autodiagnóstico de falhas,
+direcionamento automático do fluxo de ar, desumidificação, gás refrigerante ecológico R-32,
+instalação simplificada, conexões de cobre, garantia estendida de três anos no compressor e
+certificação de eficiência energética classe A pelo Procel, ideal para ambientes de até vinte
+metros quadrados, salas de estar, quartos, consultórios e pequenos escritórios comerciais.`
+)
+
+func sampleProduct(description string) *crawler.Product {
+	return &crawler.Product{
+		ID:              "prod-1001",
+		DisplayName:     "Ar-Condicionado Split Inverter Elgin Eco Power 12.000 BTUs Quente/Frio 220V",
+		LongDescription: description,
+		Brand:           "Elgin",
+		Btus:            "12000",
+		Ciclo:           "Quente/Frio",
+		Tecnologia:      "Inverter",
+		Voltagem:        "220V",
+		Fase:            "Monofásico",
+		Serpentina:      "Cobre",
+		Categoria:       "Ar-Condicionado",
+		Tipo:            "Split Hi-Wall",
+		Length:          84.5,
+		Width:           20.1,
+		Height:          29.3,
+		Weight:          9.2,
+		SalePrice:       2899.9,
+		URL:             "https://www.exemplo.com.br/ar-condicionado-split-elgin-12000/p/prod-1001",
+		ImageURL:        "https://www.exemplo.com.br/img/prod-1001.jpg",
+	}
+}
+
+// catalogProduct monta um produto com o longDescription HTML de testdata, no
+// formato devolvido pela API OCC da loja.
+func catalogProduct(t *testing.T, file string, p crawler.Product) *crawler.Product {
+	t.Helper()
+	html, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("lendo %s: %v", file, err)
+	}
+	p.LongDescription = string(html)
+	return &p
+}
+
+func TestChunkerSplit(t *testing.T) {
+	tokenizer, err := NewTokenizer("text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("NewTokenizer: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		product       *crawler.Product
+		maxTokens     int
+		overlapTokens int
+		minChunks     int
+		maxChunks     int
+		// wholeSections exige que cada seção do documento fique inteira em um chunk
+		wholeSections bool
+	}{
+		{
+			name:          "documento pequeno em um chunk",
+			product:       sampleProduct(descricaoSplit),
+			maxTokens:     DefaultChunkMaxTokens,
+			overlapTokens: DefaultChunkOverlapTokens,
+			minChunks:     1,
+			maxChunks:     1,
+			wholeSections: true,
+		},
+		{
+			name:          "seções separadas sem quebrar",
+			product:       sampleProduct(descricaoSplit),
+			maxTokens:     200,
+			overlapTokens: 20,
+			minChunks:     2,
+			maxChunks:     3,
+			wholeSections: true,
+		},
+		{
+			name:          "seção grande quebrada por linha com sobreposição",
+			product:       sampleProduct(descricaoSplit + "\n\n" + descricaoSplit),
+			maxTokens:     120,
+			overlapTokens: 15,
+			minChunks:     3,
+			maxChunks:     10,
+		},
+		{
+			name:          "linha única grande quebrada por palavra",
+			product:       sampleProduct(strings.Join(strings.Fields(descricaoLonga), " ")),
+			maxTokens:     110,
+			overlapTokens: 10,
+			minChunks:     3,
+			maxChunks:     20,
+		},
+		{
+			name:          "título grande vira seção comum",
+			product:       sampleProduct(descricaoSplit),
+			maxTokens:     80,
+			overlapTokens: 10,
+			minChunks:     3,
+			maxChunks:     20,
+		},
+		{
+			name: "descrição do catálogo: split com tabela de especificações",
+			product: catalogProduct(t, "split_inverter.html", crawler.Product{
+				ID: "kit11106", DisplayName: "Ar-Condicionado Split Inverter Samsung WindFree 12.000 BTUs Frio 220V",
+				Brand: "Samsung", Btus: "12000", Ciclo: "Frio", Tecnologia: "Inverter", Voltagem: "220V",
+				Categoria: "Ar-Condicionado", SalePrice: 3199, URL: "https://www.frigelar.com.br/p/kit11106",
+			}),
+			maxTokens:     DefaultChunkMaxTokens,
+			overlapTokens: DefaultChunkOverlapTokens,
+			minChunks:     1,
+			maxChunks:     2,
+			wholeSections: true,
+		},
+		{
+			name: "descrição do catálogo: piso teto quebrada com sobreposição",
+			product: catalogProduct(t, "piso_teto.html", crawler.Product{
+				ID: "kit9428", DisplayName: "Ar-Condicionado Piso Teto Elgin Eco 36.000 BTUs Quente/Frio 220V Trifásico",
+				Brand: "Elgin", Btus: "36000", Ciclo: "Quente/Frio", Voltagem: "220V", Fase: "Trifásico",
+				Categoria: "Ar-Condicionado", SalePrice: 11899, URL: "https://www.frigelar.com.br/p/kit9428",
+			}),
+			maxTokens:     150,
+			overlapTokens: 20,
+			minChunks:     3,
+			maxChunks:     10,
+		},
+		{
+			name: "descrição do catálogo: peça com lista",
+			product: catalogProduct(t, "kit_instalacao.html", crawler.Product{
+				ID: "16548", DisplayName: "Kit Instalação Ar-Condicionado Split 9.000 a 12.000 BTUs 3 Metros",
+				Categoria: "Peças", CategoryPath: "departamentos/pecas", SalePrice: 389.9,
+				URL: "https://www.frigelar.com.br/p/16548",
+			}),
+			maxTokens:     120,
+			overlapTokens: 15,
+			minChunks:     2,
+			maxChunks:     10,
+		},
+		{
+			name:          "sem sobreposição",
+			product:       sampleProduct(descricaoLonga),
+			maxTokens:     100,
+			overlapTokens: 0,
+			minChunks:     2,
+			maxChunks:     10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := crawler.ProductToText(tt.product)
+			c := NewChunker(tokenizer, tt.maxTokens, tt.overlapTokens)
+			chunks := c.Split(text)
+
+			if len(chunks) < tt.minChunks || len(chunks) > tt.maxChunks {
+				t.Fatalf("esperava entre %d e %d chunks, veio %d", tt.minChunks, tt.maxChunks, len(chunks))
+			}
+
+			// Título acima de MaxTokens/4 não é repetido nos chunks seguintes
+			title := tt.product.DisplayName
+			if tokenizer.Count(title) > tt.maxTokens/4 {
+				title = ""
+			}
+			var contents []string
+			for i, ch := range chunks {
+				if ch.Index != i {
+					t.Errorf("chunk %d com Index %d", i, ch.Index)
+				}
+				if !utf8.ValidString(ch.Text) || !utf8.ValidString(ch.Content) {
+					t.Errorf("chunk %d com UTF-8 inválido", i)
+				}
+				if got := tokenizer.Count(ch.Text); got != ch.Tokens {
+					t.Errorf("chunk %d: Tokens = %d, contagem real %d", i, ch.Tokens, got)
+				}
+				// O orçamento vale para o texto enviado ao modelo: título + sobreposição + conteúdo
+				if ch.Tokens > tt.maxTokens {
+					t.Errorf("chunk %d com %d tokens, limite %d", i, ch.Tokens, tt.maxTokens)
+				}
+				if (i == 0 || title != "") && !strings.HasPrefix(ch.Text, tt.product.DisplayName) {
+					t.Errorf("chunk %d não começa pelo título: %q", i, ch.Text)
+				}
+				if !strings.HasSuffix(ch.Text, ch.Content) {
+					t.Errorf("chunk %d: Text não termina com Content", i)
+				}
+				if i > 0 {
+					checkOverlap(t, tokenizer, title, chunks[i-1].Content, ch, tt.overlapTokens)
+				}
+				contents = append(contents, ch.Content)
+			}
+
+			// Os Contents reconstroem o documento, palavra por palavra
+			want := strings.Fields(text)
+			got := strings.Fields(strings.Join(contents, "\n"))
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("conteúdo dos chunks não reconstrói o documento:\n got: %q\nwant: %q", got, want)
+			}
+
+			if tt.wholeSections {
+				for _, s := range splitSections(strings.TrimSpace(text)) {
+					found := 0
+					for _, content := range contents {
+						if strings.Contains(content, s) {
+							found++
+						}
+					}
+					if found != 1 {
+						t.Errorf("seção presente inteira em %d chunks, esperava 1: %q", found, s)
+					}
+				}
+			}
+		})
+	}
+}
+
+// checkOverlap confere que o texto do chunk é título + fim do chunk anterior +
+// conteúdo novo, e que a sobreposição cabe em overlapTokens.
+func checkOverlap(t *testing.T, tokenizer Tokenizer, title, previous string, ch Chunk, overlapTokens int) {
+	t.Helper()
+
+	middle := strings.TrimPrefix(ch.Text, title)
+	middle = strings.TrimSuffix(middle, ch.Content)
+	overlap := strings.TrimSpace(middle)
+
+	if overlapTokens == 0 {
+		if overlap != "" {
+			t.Errorf("chunk %d com sobreposição %q sem overlapTokens", ch.Index, overlap)
+		}
+		return
+	}
+	if overlap == "" {
+		t.Errorf("chunk %d sem sobreposição", ch.Index)
+		return
+	}
+	if n := tokenizer.Count(overlap); n > overlapTokens {
+		t.Errorf("chunk %d: sobreposição com %d tokens, limite %d", ch.Index, n, overlapTokens)
+	}
+	prevWords := strings.Fields(previous)
+	overlapWords := strings.Fields(overlap)
+	if len(overlapWords) > len(prevWords) ||
+		strings.Join(prevWords[len(prevWords)-len(overlapWords):], " ") != overlap {
+		t.Errorf("chunk %d: sobreposição %q não é o fim do chunk anterior", ch.Index, overlap)
+	}
+}
+
+func TestChunkerSplitMultiByte(t *testing.T) {
+	tokenizer, err := NewTokenizer("text-embedding-3-small")
+	if err != nil {
+		t.Fatalf("NewTokenizer: %v", err)
+	}
+
+	// Palavras acentuadas coladas, sem espaços: a quebra por palavra não tem
+	// onde cortar e o chunk não pode partir um caractere ao meio
+	word := strings.Repeat("çãoéíõú", 60)
+	text := crawler.ProductToText(sampleProduct(word))
+
+	c := NewChunker(tokenizer, 60, 10)
+	chunks := c.Split(text)
+	var rebuilt strings.Builder
+	for _, ch := range chunks {
+		if !utf8.ValidString(ch.Text) || !utf8.ValidString(ch.Content) {
+			t.Fatalf("chunk %d com UTF-8 inválido: %q", ch.Index, ch.Content)
+		}
+		if ch.Tokens > c.MaxTokens {
+			t.Errorf("chunk %d com %d tokens, limite %d", ch.Index, ch.Tokens, c.MaxTokens)
+		}
+		rebuilt.WriteString(ch.Content)
+	}
+	// A palavra é quebrada entre caracteres, sem perder nenhum
+	if !strings.Contains(strings.Join(strings.Fields(rebuilt.String()), ""), word) {
+		t.Errorf("a palavra longa não foi preservada nos chunks")
+	}
+
+	// Bytes inválidos na entrada são descartados antes da divisão
+	for _, ch := range c.Split("Título\n\nDescrição:\nação\xffreação") {
+		if !utf8.ValidString(ch.Text) {
+			t.Fatalf("chunk %d com UTF-8 inválido: %q", ch.Index, ch.Text)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"iaprj/internal/config"
+)
+
+// DefaultModel é o modelo de embedding usado quando EMBEDDING_MODEL não é definido.
+const DefaultModel = "text-embedding-3-small"
+
+// defaultDimensions é a dimensão nativa dos modelos da OpenAI.
+var defaultDimensions = map[string]int{
+	"text-embedding-3-small": 1536,
+	"text-embedding-3-large": 3072,
+	"text-embedding-ada-002": 1536,
+}
+
+// Embedder gera embeddings tanto na ingestão quanto nas buscas do chat.
+type Embedder interface {
+	// Model identifica o modelo que gerou os vetores.
+	Model() string
+	// Embed devolve um vetor por texto, na mesma ordem.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbedder cria o Embedder configurado em EMBEDDING_PROVIDER:
+// "openai" (padrão), "compatible" (servidor local com API da OpenAI, como
+// Ollama ou LocalAI, em EMBEDDING_BASE_URL) ou "hash" (determinístico, sem rede).
+func NewEmbedder(cfg *config.Config) (Embedder, error) {
+	return NewEmbedderFor(cfg, cfg.EmbeddingModel, cfg.EmbeddingDimensions)
+}
+
+// NewEmbedderFor cria o Embedder do provider configurado para um modelo e
+// dimensão específicos, como os de um índice registrado em embedding_indexes.
+func NewEmbedderFor(cfg *config.Config, model string, dimensions int) (Embedder, error) {
+	switch cfg.EmbeddingProvider {
+	case "", "openai":
+		return NewOpenAIEmbedder(cfg.OpenAIKey, model, dimensions), nil
+	case "compatible":
+		if cfg.EmbeddingBaseURL == "" {
+			return nil, fmt.Errorf("EMBEDDING_BASE_URL é obrigatório para EMBEDDING_PROVIDER=compatible")
+		}
+		return NewCompatibleEmbedder(cfg.EmbeddingBaseURL, cfg.OpenAIKey, model), nil
+	case "hash":
+		return NewHashEmbedder(dimensions), nil
+	default:
+		return nil, fmt.Errorf("EMBEDDING_PROVIDER desconhecido: %s", cfg.EmbeddingProvider)
+	}
+}
+
+// OpenAIEmbedder usa a API de embeddings da OpenAI ou de um servidor compatível.
+type OpenAIEmbedder struct {
+	Client     *openai.Client
+	ModelName  string
+	Dimensions int // 0 = dimensão padrão do modelo
+}
+
+func NewOpenAIEmbedder(apiKey, model string, dimensions int) *OpenAIEmbedder {
+	if model == "" {
+		model = DefaultModel
+	}
+	// Só os modelos text-embedding-3 aceitam reduzir a dimensão
+	if !strings.HasPrefix(model, "text-embedding-3") || dimensions == defaultDimensions[model] {
+		dimensions = 0
+	}
+	return &OpenAIEmbedder{Client: openai.NewClient(apiKey), ModelName: model, Dimensions: dimensions}
+}
+
+// NewCompatibleEmbedder aponta o cliente da OpenAI para outro servidor
+// (ex: http://localhost:11434/v1 no Ollama).
+func NewCompatibleEmbedder(baseURL, apiKey, model string) *OpenAIEmbedder {
+	clientCfg := openai.DefaultConfig(apiKey)
+	clientCfg.BaseURL = strings.TrimRight(baseURL, "/")
+	return &OpenAIEmbedder{Client: openai.NewClientWithConfig(clientCfg), ModelName: model}
+}
+
+// Model inclui a dimensão quando ela foi reduzida (ex: text-embedding-3-large@1024),
+// para que vetores de tamanhos diferentes nunca sejam confundidos no cache.
+func (e *OpenAIEmbedder) Model() string {
+	if e.Dimensions > 0 {
+		return fmt.Sprintf("%s@%d", e.ModelName, e.Dimensions)
+	}
+	return e.ModelName
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := e.Client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Model:      openai.EmbeddingModel(e.ModelName),
+		Input:      texts,
+		Dimensions: e.Dimensions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("esperados %d embeddings, recebidos %d", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("índice de embedding inválido: %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// HashEmbedder gera vetores determinísticos por feature hashing das palavras.
+// Textos com as mesmas palavras ficam próximos, o que basta para rodar o
+// pipeline inteiro em testes e ambientes locais sem rede.
+type HashEmbedder struct {
+	Dimensions int
+}
+
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = 1536
+	}
+	return &HashEmbedder{Dimensions: dimensions}
+}
+
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.Dimensions)
+}
+
+func (e *HashEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.vector(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) vector(text string) []float32 {
+	v := make([]float32, e.Dimensions)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		h := fnv.New64a()
+		h.Write([]byte(w))
+		sum := h.Sum64()
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		v[sum%uint64(e.Dimensions)] += sign
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm > 0 {
+		n := float32(math.Sqrt(norm))
+		for i := range v {
+			v[i] /= n
+		}
+	}
+	return v
+}
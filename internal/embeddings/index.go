@@ -0,0 +1,79 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"iaprj/internal/config"
+	"iaprj/internal/repository"
+)
+
+// ActiveIndexEmbedder gera os embeddings das buscas sempre com o modelo do
+// índice ativo em embedding_indexes. O registro é consultado a cada busca (é
+// uma tabela de poucas linhas), então depois de uma troca de índice o chat
+// passa a usar o novo modelo imediatamente, sem precisar ser reiniciado.
+type ActiveIndexEmbedder struct {
+	Config  *config.Config
+	Indexes *repository.EmbeddingIndexRepository
+	Cache   *repository.EmbeddingCacheRepository
+
+	mu      sync.Mutex
+	current Embedder
+	key     string
+}
+
+func NewActiveIndexEmbedder(cfg *config.Config, indexes *repository.EmbeddingIndexRepository, cache *repository.EmbeddingCacheRepository) (*ActiveIndexEmbedder, error) {
+	e := &ActiveIndexEmbedder{Config: cfg, Indexes: indexes, Cache: cache}
+	if _, err := e.resolve(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *ActiveIndexEmbedder) Model() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.current.Model()
+}
+
+func (e *ActiveIndexEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder, err := e.resolve()
+	if err != nil {
+		return nil, err
+	}
+	return embedder.Embed(ctx, texts)
+}
+
+// resolve consulta o índice ativo e recria o Embedder quando o modelo muda.
+// Se a consulta falhar, continua com o modelo atual.
+func (e *ActiveIndexEmbedder) resolve() (Embedder, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	idx, err := e.Indexes.Active()
+	if err != nil {
+		if e.current != nil {
+			log.Printf("[Embeddings] Erro ao consultar índice ativo, mantendo %s: %v", e.current.Model(), err)
+			return e.current, nil
+		}
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%s/%d", idx.Model, idx.Dimensions)
+	if e.current != nil && key == e.key {
+		return e.current, nil
+	}
+
+	embedder, err := NewEmbedderFor(e.Config, idx.Model, idx.Dimensions)
+	if err != nil {
+		return nil, err
+	}
+	if e.Cache != nil {
+		embedder = NewCachedEmbedder(embedder, e.Cache)
+	}
+	log.Printf("[Embeddings] Índice ativo: %s (%d dimensões)", idx.Model, idx.Dimensions)
+	e.current, e.key = embedder, key
+	return embedder, nil
+}
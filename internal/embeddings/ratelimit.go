@@ -0,0 +1,200 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+
+	"iaprj/internal/observability"
+)
+
+// RateLimiter controla o orçamento de tokens e de requisições por minuto da
+// API de embeddings. Uma única instância é compartilhada por todos os workers.
+type RateLimiter struct {
+	mu       sync.Mutex
+	tpm      float64
+	rpm      float64
+	tokens   float64
+	requests float64
+	last     time.Time
+}
+
+// NewRateLimiter cria o limitador; valores <= 0 desativam o respectivo limite.
+func NewRateLimiter(tpm, rpm int) *RateLimiter {
+	return &RateLimiter{
+		tpm:      float64(tpm),
+		rpm:      float64(rpm),
+		tokens:   float64(tpm),
+		requests: float64(rpm),
+		last:     time.Now(),
+	}
+}
+
+// Wait bloqueia até haver orçamento para uma requisição com n tokens.
+func (l *RateLimiter) Wait(ctx context.Context, n int) error {
+	need := float64(n)
+	// Uma requisição maior que o orçamento inteiro esperaria para sempre
+	if l.tpm > 0 && need > l.tpm {
+		need = l.tpm
+	}
+
+	for {
+		wait := l.reserve(need)
+		if wait == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reserve desconta o orçamento quando disponível ou retorna quanto esperar.
+func (l *RateLimiter) reserve(need float64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(l.last).Minutes()
+	l.last = now
+	l.tokens = min(l.tpm, l.tokens+elapsed*l.tpm)
+	l.requests = min(l.rpm, l.requests+elapsed*l.rpm)
+
+	var wait time.Duration
+	if l.tpm > 0 && l.tokens < need {
+		wait = max(wait, time.Duration((need-l.tokens)/l.tpm*float64(time.Minute)))
+	}
+	if l.rpm > 0 && l.requests < 1 {
+		wait = max(wait, time.Duration((1-l.requests)/l.rpm*float64(time.Minute)))
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	if l.tpm > 0 {
+		l.tokens -= need
+	}
+	if l.rpm > 0 {
+		l.requests--
+	}
+	return 0
+}
+
+// RateLimitedEmbedder aplica o orçamento do RateLimiter e repete com backoff
+// exponencial as requisições recusadas por limite (429) ou erro do servidor (5xx).
+type RateLimitedEmbedder struct {
+	Embedder   Embedder
+	Limiter    *RateLimiter
+	Tokenizer  Tokenizer
+	MaxRetries int
+}
+
+func NewRateLimitedEmbedder(embedder Embedder, limiter *RateLimiter, tokenizer Tokenizer, maxRetries int) *RateLimitedEmbedder {
+	return &RateLimitedEmbedder{Embedder: embedder, Limiter: limiter, Tokenizer: tokenizer, MaxRetries: maxRetries}
+}
+
+func (e *RateLimitedEmbedder) Model() string {
+	return e.Embedder.Model()
+}
+
+func (e *RateLimitedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	tokens := 0
+	for _, t := range texts {
+		tokens += e.Tokenizer.Count(t)
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if err := e.Limiter.Wait(ctx, tokens); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		vectors, err := e.Embedder.Embed(ctx, texts)
+		observeCall(e.Model(), tokens, time.Since(start), err)
+		if err == nil || !retryable(err) || attempt >= e.MaxRetries {
+			return vectors, err
+		}
+
+		// Jitter evita que os workers voltem todos ao mesmo tempo
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)))
+		log.Printf("[Embeddings] Tentativa %d falhou (%v), nova tentativa em %s", attempt+1, err, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+// observeCall registra latência, tokens e custo estimado de uma chamada à API.
+func observeCall(model string, tokens int, elapsed time.Duration, err error) {
+	observability.EmbeddingAPIDuration.WithLabelValues(model).Observe(elapsed.Seconds())
+	if err != nil {
+		return
+	}
+	observability.EmbeddingTokensTotal.WithLabelValues(model).Add(float64(tokens))
+	observability.EmbeddingCostUSD.WithLabelValues(model).Add(EstimatedCost(model, tokens))
+}
+
+// pricePerMillionTokens é o preço de tabela da OpenAI, em dólares por milhão de tokens.
+var pricePerMillionTokens = map[string]float64{
+	"text-embedding-3-small": 0.02,
+	"text-embedding-3-large": 0.13,
+	"text-embedding-ada-002": 0.10,
+}
+
+// EstimatedCost estima o custo em dólares de embeddar tokens com o modelo.
+// Modelos locais ou desconhecidos custam zero.
+func EstimatedCost(model string, tokens int) float64 {
+	name, _, _ := strings.Cut(model, "@")
+	return pricePerMillionTokens[name] * float64(tokens) / 1e6
+}
+
+// failureReason classifica o erro de um lote para a métrica de falhas.
+func failureReason(err error) string {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	code := 0
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	case errors.As(err, &apiErr):
+		code = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		code = reqErr.HTTPStatusCode
+	default:
+		return "other"
+	}
+	switch {
+	case code == http.StatusTooManyRequests:
+		return "rate_limit"
+	case code >= 500:
+		return "server_error"
+	default:
+		return "api_error"
+	}
+}
+
+// retryable indica se o erro da API é temporário (limite de taxa ou erro do servidor).
+func retryable(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests || apiErr.HTTPStatusCode >= 500
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusTooManyRequests || reqErr.HTTPStatusCode >= 500
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package embeddings
+
+import (
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+func init() {
+	// Usa os arquivos BPE embutidos no binário em vez de baixá-los em tempo de execução
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// Tokenizer conta tokens da mesma forma que o modelo de embedding.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+type tiktokenTokenizer struct {
+	enc *tiktoken.Tiktoken
+}
+
+// NewTokenizer retorna o tokenizer do modelo (cl100k_base para os modelos
+// text-embedding-3-*), caindo para cl100k_base em modelos desconhecidos.
+func NewTokenizer(model string) (Tokenizer, error) {
+	enc, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		enc, err = tiktoken.GetEncoding(tiktoken.MODEL_CL100K_BASE)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &tiktokenTokenizer{enc: enc}, nil
+}
+
+func (t *tiktokenTokenizer) Count(text string) int {
+	return len(t.enc.Encode(text, nil, nil))
+}
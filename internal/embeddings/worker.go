@@ -1,64 +1,168 @@
 package embeddings
 
 import (
+	"context"
 	"log"
 	"sync"
 
 	"iaprj/internal/model"
+	"iaprj/internal/observability"
 	"iaprj/internal/repository"
 )
 
+// ChunkWriter grava o conjunto de chunks de um produto (repository.VectorRepository
+// nos índices ativo e sombra).
+type ChunkWriter interface {
+	ReplaceChunks(p model.RawProduct, embeddingModel string, chunks []repository.ProductChunk) error
+}
+
+// RunWorkers gera os embeddings com workers goroutines. Quando ctx é cancelado
+// (SIGINT/SIGTERM) nenhum lote novo é iniciado, mas os lotes em andamento
+// terminam: as chamadas à API já pagas não são descartadas. Como cada produto
+// é gravado em uma transação e só então sai da fila, os que não começaram
+// continuam pendentes para a próxima execução. Retorna os produtos que falharam
+// (exceto por interrupção), para quem precisa tratá-los além da fila de falhas.
 func RunWorkers(
+	ctx context.Context,
 	products []model.RawProduct,
-	vectorRepo *repository.VectorRepository,
+	chunker *Chunker,
+	embedder Embedder,
+	vectorRepo ChunkWriter,
 	rawRepo *repository.RawRepository,
 	workers int,
-) {
+) []Failure {
 
-	const maxWorkers = 8
-	jobs := make(chan model.RawProduct)
+	if workers < 1 {
+		workers = 1
+	}
+	batches := make(chan *embeddingBatch)
+	inFlight := context.WithoutCancel(ctx)
+	failures := &failureList{}
 	var wg sync.WaitGroup
 
-	for i := 0; i < maxWorkers; i++ {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for p := range jobs {
-				process(p, vectorRepo, rawRepo)
+			for b := range batches {
+				processBatch(inFlight, b, embedder, vectorRepo, rawRepo, failures)
 			}
 		}()
 	}
 
-	for _, p := range products {
-		jobs <- p
-	}
-	close(jobs)
+	buildBatches(ctx, products, chunker, MaxBatchInputs, MaxBatchTokens, batches)
 	wg.Wait()
+
+	if ctx.Err() != nil {
+		log.Printf("Geração de embeddings interrompida; produtos não concluídos continuam pendentes")
+	}
+	return failures.list
 }
 
-func process(
-	p model.RawProduct,
-	vectorRepo *repository.VectorRepository,
+// Failure é um produto cujo embedding não pôde ser gerado ou gravado.
+type Failure struct {
+	ProdutoID string
+	Reason    string
+	Err       error
+}
+
+// failureList junta as falhas de todos os workers.
+type failureList struct {
+	mu   sync.Mutex
+	list []Failure
+}
+
+func (l *failureList) add(f Failure) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.list = append(l.list, f)
+}
+
+// processBatch gera os embeddings de todos os chunks do lote em uma requisição
+// e salva cada produto com os vetores correspondentes. Se a API recusar o lote
+// (erro 4xx que não é limite de taxa), ele é dividido ao meio até isolar os
+// produtos com problema, para que só eles sejam registrados como falha.
+func processBatch(
+	ctx context.Context,
+	b *embeddingBatch,
+	embedder Embedder,
+	vectorRepo ChunkWriter,
 	rawRepo *repository.RawRepository,
+	failures *failureList,
 ) {
-	chunks := Chunk(p.Content, 1000)
-	success := true
-	for _, c := range chunks {
-		embedding, err := Embed(c)
-		if err != nil {
-			log.Printf("Erro ao gerar embedding para %s: %v", p.ProdutoID, err)
-			success = false
-			continue
+	log.Printf("Gerando embeddings de %d chunks (%d tokens) de %d produtos", b.inputs, b.tokens, len(b.items))
+
+	var vectors [][]float32
+	var err error
+	if b.inputs > 0 {
+		vectors, err = embedder.Embed(ctx, b.texts())
+	}
+	if err != nil {
+		reason := failureReason(err)
+		if reason == "api_error" && len(b.items) > 1 {
+			log.Printf("Lote recusado pela API (%v), dividindo para isolar o produto com erro", err)
+			mid := len(b.items) / 2
+			processBatch(ctx, newBatch(b.items[:mid]), embedder, vectorRepo, rawRepo, failures)
+			processBatch(ctx, newBatch(b.items[mid:]), embedder, vectorRepo, rawRepo, failures)
+			return
 		}
-		if err := vectorRepo.Save(p.ProdutoID, p.SourceURL, p.ImageURL, p.Brand, p.Btus, p.Ciclo, p.Voltagem, p.Tecnologia, p.Type, c, embedding, p.SalePrice, p.Length, p.Weight, p.Width, p.Height); err != nil {
-			log.Printf("Erro ao salvar vetor para %s: %v", p.ProdutoID, err)
-			success = false
+
+		observability.EmbeddingFailuresTotal.WithLabelValues(reason).Add(float64(len(b.items)))
+		for _, item := range b.items {
+			log.Printf("Erro ao gerar embedding para %s: %v", item.product.ProdutoID, err)
+			recordFailure(rawRepo, failures, item.product.ProdutoID, reason, err)
 		}
+		return
+	}
+
+	offset := 0
+	for _, item := range b.items {
+		process(item, embedder.Model(), vectors[offset:offset+len(item.chunks)], vectorRepo, rawRepo, failures)
+		offset += len(item.chunks)
 	}
-	if success {
+}
+
+// process grava os chunks do produto. rawRepo é nil na construção de um índice
+// sombra, que não altera a fila de embeddings do índice ativo.
+func process(
+	item productChunks,
+	embeddingModel string,
+	vectors [][]float32,
+	vectorRepo ChunkWriter,
+	rawRepo *repository.RawRepository,
+	failures *failureList,
+) {
+	p := item.product
+	chunks := make([]repository.ProductChunk, len(item.chunks))
+	for i, c := range item.chunks {
+		chunks[i] = repository.ProductChunk{Index: c.Index, Content: c.Content, Embedding: vectors[i]}
+	}
+	if err := vectorRepo.ReplaceChunks(p, embeddingModel, chunks); err != nil {
+		observability.EmbeddingFailuresTotal.WithLabelValues("database").Inc()
+		log.Printf("Erro ao salvar vetores para %s: %v", p.ProdutoID, err)
+		recordFailure(rawRepo, failures, p.ProdutoID, "database", err)
+		return
+	}
+	observability.EmbeddingsTotal.Add(float64(len(chunks)))
+	if rawRepo != nil {
 		rawRepo.MarkAsProcessed(p.ProdutoID)
-		log.Printf("Sucesso ao processar produto %s", p.ProdutoID)
-	} else {
-		log.Printf("Falha ao processar produto %s", p.ProdutoID)
+	}
+	log.Printf("Sucesso ao processar produto %s", p.ProdutoID)
+}
+
+// recordFailure coloca o produto na fila de falhas (embedding_failures).
+// Interrupções por SIGINT/SIGTERM não contam como tentativa, e a construção
+// de um índice sombra (rawRepo nil) não usa a fila.
+func recordFailure(rawRepo *repository.RawRepository, failures *failureList, produtoID, reason string, err error) {
+	log.Printf("Falha ao processar produto %s", produtoID)
+	if reason == "canceled" {
+		return
+	}
+	failures.add(Failure{ProdutoID: produtoID, Reason: reason, Err: err})
+	if rawRepo == nil {
+		return
+	}
+	if err := rawRepo.RecordEmbeddingFailure(produtoID, reason, err.Error()); err != nil {
+		log.Printf("Erro ao registrar falha de embedding de %s: %v", produtoID, err)
 	}
 }
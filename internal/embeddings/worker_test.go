@@ -0,0 +1,95 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"iaprj/internal/crawler"
+	"iaprj/internal/model"
+	"iaprj/internal/repository"
+)
+
+// memoryChunkWriter guarda em memória o que seria gravado no índice.
+type memoryChunkWriter struct {
+	mu     sync.Mutex
+	saved  map[string][]repository.ProductChunk
+	models map[string]string
+	fail   map[string]bool
+}
+
+func (w *memoryChunkWriter) ReplaceChunks(p model.RawProduct, embeddingModel string, chunks []repository.ProductChunk) error {
+	if w.fail[p.ProdutoID] {
+		return errors.New("conexão perdida")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.saved[p.ProdutoID] = chunks
+	w.models[p.ProdutoID] = embeddingModel
+	return nil
+}
+
+func TestRunWorkersHashEmbedder(t *testing.T) {
+	tokenizer, err := NewTokenizer("text-embedding-3-small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunker := NewChunker(tokenizer, 80, 10)
+	embedder := NewHashEmbedder(64)
+
+	rawProduct := func(id, description string) model.RawProduct {
+		p := sampleProduct(description)
+		p.ID = id
+		return crawler.ToRawProduct(*p)
+	}
+	products := []model.RawProduct{
+		rawProduct("p1", descricaoSplit),
+		rawProduct("p2", descricaoLonga),
+		rawProduct("p3", descricaoSplit+"\n\n"+descricaoLonga),
+		{ProdutoID: "p4"}, // sem conteúdo: grava o conjunto vazio
+		rawProduct("p5", descricaoSplit),
+	}
+
+	writer := &memoryChunkWriter{
+		saved:  map[string][]repository.ProductChunk{},
+		models: map[string]string{},
+		fail:   map[string]bool{"p5": true},
+	}
+	failures := RunWorkers(context.Background(), products, chunker, embedder, writer, nil, 2)
+
+	if len(failures) != 1 || failures[0].ProdutoID != "p5" || failures[0].Reason != "database" {
+		t.Fatalf("falhas = %+v, esperava só p5 com motivo database", failures)
+	}
+
+	for _, p := range products[:4] {
+		got, ok := writer.saved[p.ProdutoID]
+		if !ok {
+			t.Errorf("%s: chunks não gravados", p.ProdutoID)
+			continue
+		}
+		if writer.models[p.ProdutoID] != embedder.Model() {
+			t.Errorf("%s: modelo = %q, esperava %q", p.ProdutoID, writer.models[p.ProdutoID], embedder.Model())
+		}
+
+		// Cada chunk recebe o vetor do próprio texto, mesmo com vários produtos no lote
+		want := chunker.Split(p.Content)
+		if len(got) != len(want) {
+			t.Errorf("%s: %d chunks gravados, esperava %d", p.ProdutoID, len(got), len(want))
+			continue
+		}
+		for i, c := range want {
+			vectors, _ := embedder.Embed(context.Background(), []string{c.Text})
+			if got[i].Index != c.Index || got[i].Content != c.Content {
+				t.Errorf("%s: chunk %d = (%d, %q), esperava (%d, %q)", p.ProdutoID, i, got[i].Index, got[i].Content, c.Index, c.Content)
+			}
+			if !reflect.DeepEqual(got[i].Embedding, vectors[0]) {
+				t.Errorf("%s: chunk %d recebeu o vetor de outro texto", p.ProdutoID, i)
+			}
+		}
+	}
+	if n := len(writer.saved["p3"]); n < 2 {
+		t.Errorf("p3 gerou %d chunks, o teste precisa de um produto com vários", n)
+	}
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// CrawlRun é o registro de uma execução do crawler na tabela crawl_runs.
+type CrawlRun struct {
+	ID         int64
+	Source     string
+	Category   string
+	Status     string // running, completed, failed
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	NextURL    string // próxima página a processar (checkpoint)
+	New        int
+	Changed    int
+	Unchanged  int
+	Failed     int
+	Errors     string
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// Status de um índice de embeddings.
+const (
+	IndexBuilding = "building" // tabela sombra sendo populada
+	IndexActive   = "active"   // product_knowledge servindo as buscas
+	IndexRetired  = "retired"
+)
+
+// EmbeddingFailure é um produto na fila de falhas de embedding.
+type EmbeddingFailure struct {
+	ProdutoID     string
+	Reason        string
+	Error         string
+	Attempts      int
+	FirstFailedAt time.Time
+	LastAttemptAt time.Time
+}
+
+// EmbeddingIndex registra qual modelo (e dimensão) gerou os vetores de um índice.
+type EmbeddingIndex struct {
+	Model       string
+	Dimensions  int
+	Status      string
+	CreatedAt   time.Time
+	ActivatedAt *time.Time
+}
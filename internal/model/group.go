@@ -0,0 +1,23 @@
+package model
+
+// ProductGroupMember liga um produto ao grupo de anúncios do mesmo modelo.
+// GroupID é o produto canônico do grupo.
+type ProductGroupMember struct {
+	ProdutoID string
+	GroupID   string
+	Canonical bool
+	Reason    string
+}
+
+// DedupCandidate reúne os dados usados para detectar produtos duplicados.
+type DedupCandidate struct {
+	ProdutoID string
+	Name      string
+	Brand     string
+	Btus      int
+	Voltagem  string
+	Ciclo     string
+	Type      string
+	SalePrice float32
+	Embedding []float32
+}
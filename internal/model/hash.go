@@ -0,0 +1,13 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ContentHash calcula o hash usado para detectar mudanças no texto de um produto
+// e identificar textos no cache de embeddings.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// PriceTrend resume a evolução do preço de venda de um produto em uma janela de dias.
+type PriceTrend struct {
+	ProdutoID     string
+	CurrentPrice  float32
+	PreviousPrice float32 // preço observado no início da janela (0 se não houver histórico)
+	PreviousAt    time.Time
+	LowestPrice   float32
+	LowestAt      time.Time
+	Days          int
+}
+
+// Change é a variação do preço desde o início da janela (negativo = ficou mais barato).
+func (t PriceTrend) Change() float32 {
+	if t.PreviousPrice == 0 {
+		return 0
+	}
+	return t.CurrentPrice - t.PreviousPrice
+}
@@ -1,20 +1,65 @@
 package model
 
 type RawProduct struct {
-	ID         string
-	ProdutoID  string
-	SourceURL  string
-	ImageURL   string
-	Brand      string // Nova coluna estruturada
-	Btus       int    // Nova coluna estruturada
-	Ciclo      string
-	Voltagem   string
-	Tecnologia string
-	Type       string
-	Content    string
-	SalePrice  float32 // Nova coluna estruturada
-	Length     float32 // Nova coluna estruturada
-	Weight     float32 // Nova coluna estruturada
-	Width      float32 // Nova coluna estruturada
-	Height     float32 // Nova coluna estruturada
+	ID           string
+	ProdutoID    string
+	Source       string
+	CategoryID   string
+	CategoryPath string // caminho da categoria no catálogo (ex: ar-condicionado/split)
+	SourceURL    string
+	ImageURL     string
+	Brand        string // Nova coluna estruturada
+	Btus         int    // Nova coluna estruturada
+	Ciclo        string
+	Voltagem     string
+	Tecnologia   string
+	Type         string
+	RuidoDB      float32 // nível de ruído em dB(A)
+	Procel       string  // classe do selo Procel (A-G)
+	Gas          string  // gás refrigerante (ex: R-410A)
+	Content      string
+	SalePrice    float32 // Nova coluna estruturada
+	Length       float32 // Nova coluna estruturada
+	Weight       float32 // Nova coluna estruturada
+	Width        float32 // Nova coluna estruturada
+	Height       float32 // Nova coluna estruturada
+
+	// Controle de crawling incremental
+	ContentHash  string
+	LastModified string
+	ListPrice    float32
+
+	// RawJSON é o registro original da origem (ex: JSON do produto OCC)
+	RawJSON []byte
+
+	// TextTemplate é a versão do layout usada para gerar Content
+	TextTemplate string
+
+	// Categoria em que o crawl encontrou o produto. Difere de CategoryID/CategoryPath
+	// (a principal) quando o produto está em várias categorias.
+	ListedCategoryID   string
+	ListedCategoryPath string
+}
+
+// ProductComponent é uma unidade física de um kit (evaporadora, condensadora...).
+type ProductComponent struct {
+	ProdutoID          string
+	ComponentSKU       string
+	ComponentProdutoID string
+	Name               string
+	Role               string
+	Quantity           int
+	Length             float32
+	Weight             float32
+	Width              float32
+	Height             float32
+	SalePrice          float32
+}
+
+// ProductVariant liga um produto a outra versão do mesmo modelo (ex: 110V/220V).
+type ProductVariant struct {
+	ProdutoID        string
+	VariantProdutoID string
+	Label            string
+	Attribute        string
 }
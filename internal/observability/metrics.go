@@ -1,12 +1,46 @@
 package observability
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
 )
 
+// Crawler
+var (
+	CrawlerPagesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "crawler_pages_total",
+			Help: "Páginas de listagem de categoria processadas",
+		},
+	)
+	CrawlerProductsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_products_total",
+			Help: "Produtos salvos pelo crawler por origem e resultado (new, changed, unchanged, failed)",
+		},
+		[]string{"source", "status"},
+	)
+	CrawlerHTTPRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "crawler_http_requests_total",
+			Help: "Requisições HTTP do crawler por status (cada tentativa conta; \"error\" = falha de rede)",
+		},
+		[]string{"code"},
+	)
+	CrawlerHTTPDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "crawler_http_request_duration_seconds",
+			Help:    "Latência das requisições HTTP do crawler",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+)
+
+// Embeddings
 var (
 	EmbeddingsTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -14,10 +48,82 @@ var (
 			Help: "Total de embeddings gerados",
 		},
 	)
+	EmbeddingTokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "embedding_tokens_total",
+			Help: "Tokens enviados à API de embeddings",
+		},
+		[]string{"model"},
+	)
+	EmbeddingAPIDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "embedding_api_duration_seconds",
+			Help:    "Latência das chamadas à API de embeddings",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"model"},
+	)
+	EmbeddingFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "embedding_failures_total",
+			Help: "Produtos que falharam na geração de embeddings por motivo",
+		},
+		[]string{"reason"},
+	)
+	EmbeddingCostUSD = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "embedding_cost_usd_total",
+			Help: "Custo estimado das chamadas à API de embeddings, em dólares",
+		},
+		[]string{"model"},
+	)
 )
 
+// Estoque
+var (
+	StockChecksTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "stock_checks_total",
+			Help: "Consultas de disponibilidade feitas pelo job de estoque",
+		},
+	)
+	StockFlipsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stock_availability_flips_total",
+			Help: "Produtos cuja disponibilidade mudou (to = in_stock ou out_of_stock)",
+		},
+		[]string{"to"},
+	)
+	StockErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stock_errors_total",
+			Help: "Erros do job de estoque por etapa (check, update)",
+		},
+		[]string{"stage"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		CrawlerPagesTotal, CrawlerProductsTotal, CrawlerHTTPRequestsTotal, CrawlerHTTPDuration,
+		EmbeddingsTotal, EmbeddingTokensTotal, EmbeddingAPIDuration, EmbeddingFailuresTotal, EmbeddingCostUSD,
+		StockChecksTotal, StockFlipsTotal, StockErrorsTotal,
+	)
+}
+
+// Start expõe /metrics na porta informada enquanto o processo estiver rodando.
 func Start(port string) {
-	prometheus.MustRegister(EmbeddingsTotal)
 	http.Handle("/metrics", promhttp.Handler())
 	go http.ListenAndServe(":"+port, nil)
 }
+
+// Push envia as métricas do processo para um Pushgateway ao final de um job em
+// lote. Não faz nada quando url é vazia.
+func Push(url, job string) {
+	if url == "" {
+		return
+	}
+	if err := push.New(url, job).Gatherer(prometheus.DefaultGatherer).Push(); err != nil {
+		log.Printf("Erro ao enviar métricas para o Pushgateway %s: %v", url, err)
+	}
+}
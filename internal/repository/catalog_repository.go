@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"iaprj/internal/model"
+)
+
+// CatalogRepository expõe ao chat os relacionamentos de catálogo gravados pelo crawler.
+type CatalogRepository struct {
+	DB *pgxpool.Pool
+}
+
+// GetComponents retorna as unidades que compõem um kit (vazio para produtos avulsos).
+func (r *CatalogRepository) GetComponents(produtoID string) ([]model.ProductComponent, error) {
+	rows, err := r.DB.Query(context.Background(), `
+		SELECT produto_id, component_sku, COALESCE(component_produto_id, ''), name, role, quantity,
+		       length, weight, width, height, sale_price
+		FROM product_components
+		WHERE produto_id = $1
+		ORDER BY role DESC, component_sku
+	`, produtoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var components []model.ProductComponent
+	for rows.Next() {
+		var c model.ProductComponent
+		err := rows.Scan(&c.ProdutoID, &c.ComponentSKU, &c.ComponentProdutoID, &c.Name, &c.Role, &c.Quantity,
+			&c.Length, &c.Weight, &c.Width, &c.Height, &c.SalePrice)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, c)
+	}
+	return components, rows.Err()
+}
+
+// GetVariants retorna as outras versões ativas do mesmo modelo do produto.
+func (r *CatalogRepository) GetVariants(produtoID string) ([]model.ProductVariant, error) {
+	rows, err := r.DB.Query(context.Background(), `
+		SELECT v.produto_id, v.variant_produto_id, v.label, v.attribute
+		FROM product_variants v
+		JOIN product_raw_knowledge r ON r.produto_id = v.variant_produto_id
+		WHERE v.produto_id = $1 AND r.active
+		ORDER BY v.attribute, v.label
+	`, produtoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []model.ProductVariant
+	for rows.Next() {
+		var v model.ProductVariant
+		if err := rows.Scan(&v.ProdutoID, &v.VariantProdutoID, &v.Label, &v.Attribute); err == nil {
+			variants = append(variants, v)
+		}
+	}
+	return variants, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+
+	"iaprj/internal/model"
+)
+
+const (
+	CrawlRunning   = "running"
+	CrawlCompleted = "completed"
+	CrawlFailed    = "failed"
+)
+
+// CrawlRunRepository mantém o histórico de execuções do crawler, usado para
+// retomar crawls de categoria interrompidos.
+type CrawlRunRepository struct {
+	DB *sql.DB
+}
+
+func (r *CrawlRunRepository) Start(source, category string) (*model.CrawlRun, error) {
+	run := &model.CrawlRun{Source: source, Category: category, Status: CrawlRunning}
+	err := r.DB.QueryRow(`
+		INSERT INTO crawl_runs (source, category, status, started_at)
+		VALUES ($1, $2, $3, now())
+		RETURNING id, started_at
+	`, source, category, CrawlRunning).Scan(&run.ID, &run.StartedAt)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Checkpoint grava a próxima página a ser processada e os contadores parciais.
+func (r *CrawlRunRepository) Checkpoint(run *model.CrawlRun) error {
+	_, err := r.DB.Exec(`
+		UPDATE crawl_runs
+		SET next_url = $1, new_count = $2, changed_count = $3, unchanged_count = $4, failed_count = $5, status = $6
+		WHERE id = $7
+	`, run.NextURL, run.New, run.Changed, run.Unchanged, run.Failed, CrawlRunning, run.ID)
+	return err
+}
+
+// Finish encerra a execução como completed ou, se runErr não for nil, como failed.
+// O checkpoint é mantido em execuções com falha para permitir o -resume.
+func (r *CrawlRunRepository) Finish(run *model.CrawlRun, runErr error) error {
+	run.Status = CrawlCompleted
+	if runErr != nil {
+		run.Status = CrawlFailed
+		run.Errors = runErr.Error()
+	}
+	_, err := r.DB.Exec(`
+		UPDATE crawl_runs
+		SET status = $1, finished_at = now(), errors = $2,
+		    new_count = $3, changed_count = $4, unchanged_count = $5, failed_count = $6
+		WHERE id = $7
+	`, run.Status, run.Errors, run.New, run.Changed, run.Unchanged, run.Failed, run.ID)
+	return err
+}
+
+// FindResumable devolve a última execução da categoria se ela não terminou com
+// sucesso e possui checkpoint. Retorna nil quando não há nada a retomar.
+func (r *CrawlRunRepository) FindResumable(source, category string) (*model.CrawlRun, error) {
+	var run model.CrawlRun
+	var nextURL, errors sql.NullString
+	var finishedAt sql.NullTime
+	err := r.DB.QueryRow(`
+		SELECT id, source, category, status, started_at, finished_at, next_url,
+		       new_count, changed_count, unchanged_count, failed_count, errors
+		FROM crawl_runs
+		WHERE source = $1 AND category = $2
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, source, category).Scan(&run.ID, &run.Source, &run.Category, &run.Status, &run.StartedAt, &finishedAt, &nextURL,
+		&run.New, &run.Changed, &run.Unchanged, &run.Failed, &errors)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if run.Status == CrawlCompleted || nextURL.String == "" {
+		return nil, nil
+	}
+	run.NextURL = nextURL.String
+	run.Errors = errors.String
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
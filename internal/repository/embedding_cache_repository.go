@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EmbeddingCacheRepository guarda os vetores já gerados, indexados pelo modelo
+// e pelo sha256 do texto, para que o mesmo texto não seja enviado de novo à API.
+type EmbeddingCacheRepository struct {
+	DB *pgxpool.Pool
+}
+
+// Get retorna os vetores em cache para os hashes informados. Hashes sem
+// entrada simplesmente não aparecem no mapa.
+func (r *EmbeddingCacheRepository) Get(model string, hashes []string) (map[string][]float32, error) {
+	rows, err := r.DB.Query(context.Background(), `
+		SELECT content_hash, embedding::text
+		FROM embedding_cache
+		WHERE model = $1 AND content_hash = ANY($2)
+	`, model, hashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cached := make(map[string][]float32, len(hashes))
+	for rows.Next() {
+		var hash, emb string
+		if err := rows.Scan(&hash, &emb); err != nil {
+			return nil, err
+		}
+		cached[hash] = parseVector(emb)
+	}
+	return cached, rows.Err()
+}
+
+// Put grava os vetores gerados; entradas já existentes são mantidas.
+func (r *EmbeddingCacheRepository) Put(model string, vectors map[string][]float32) error {
+	ctx := context.Background()
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for hash, v := range vectors {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO embedding_cache (model, content_hash, embedding)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (model, content_hash) DO NOTHING
+		`, model, hash, formatVector(v))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"iaprj/internal/model"
+)
+
+// Tabelas do índice de embeddings. As buscas sempre leem KnowledgeTable; um
+// novo modelo é gerado em ShadowKnowledgeTable e trocado de lugar com ela
+// em SwitchOver, sem interromper o chat.
+const (
+	KnowledgeTable        = "product_knowledge"
+	ShadowKnowledgeTable  = "product_knowledge_shadow"
+	RetiredKnowledgeTable = "product_knowledge_retired"
+)
+
+// ErrNoShadowIndex indica que não há índice em construção para ativar.
+var ErrNoShadowIndex = errors.New("nenhum índice de embeddings em construção")
+
+// IncompleteShadowError indica que a tabela sombra não tem vetores atualizados
+// de todos os produtos ativos do catálogo.
+type IncompleteShadowError struct {
+	Missing []string
+}
+
+func (e *IncompleteShadowError) Error() string {
+	return fmt.Sprintf("o índice em construção não tem vetores atualizados de %d produtos ativos do catálogo", len(e.Missing))
+}
+
+// EmbeddingIndexRepository mantém o registro dos índices de embeddings
+// (embedding_indexes) e as tabelas que os guardam.
+type EmbeddingIndexRepository struct {
+	DB *pgxpool.Pool
+}
+
+// Active retorna o índice que está servindo as buscas.
+func (r *EmbeddingIndexRepository) Active() (model.EmbeddingIndex, error) {
+	return r.byStatus(model.IndexActive)
+}
+
+// Building retorna o índice em construção; ok é false quando não há nenhum.
+func (r *EmbeddingIndexRepository) Building() (idx model.EmbeddingIndex, ok bool, err error) {
+	idx, err = r.byStatus(model.IndexBuilding)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return idx, false, nil
+	}
+	return idx, err == nil, err
+}
+
+func (r *EmbeddingIndexRepository) byStatus(status string) (model.EmbeddingIndex, error) {
+	var idx model.EmbeddingIndex
+	err := r.DB.QueryRow(context.Background(), `
+		SELECT model, dimensions, status, created_at, activated_at
+		FROM embedding_indexes
+		WHERE status = $1
+	`, status).Scan(&idx.Model, &idx.Dimensions, &idx.Status, &idx.CreatedAt, &idx.ActivatedAt)
+	return idx, err
+}
+
+// List retorna todos os índices registrados, do mais recente para o mais antigo.
+func (r *EmbeddingIndexRepository) List() ([]model.EmbeddingIndex, error) {
+	rows, err := r.DB.Query(context.Background(), `
+		SELECT model, dimensions, status, created_at, activated_at
+		FROM embedding_indexes
+		ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.EmbeddingIndex
+	for rows.Next() {
+		var idx model.EmbeddingIndex
+		if err := rows.Scan(&idx.Model, &idx.Dimensions, &idx.Status, &idx.CreatedAt, &idx.ActivatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, idx)
+	}
+	return list, rows.Err()
+}
+
+// CreateShadow recria a tabela sombra com a dimensão do novo modelo e registra
+// o índice como em construção, descartando uma construção anterior inacabada.
+func (r *EmbeddingIndexRepository) CreateShadow(embeddingModel string, dimensions int) error {
+	ctx := context.Background()
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var active bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM embedding_indexes WHERE model = $1 AND dimensions = $2 AND status = $3)
+	`, embeddingModel, dimensions, model.IndexActive).Scan(&active)
+	if err != nil {
+		return err
+	}
+	if active {
+		return fmt.Errorf("o índice ativo já usa %s com %d dimensões", embeddingModel, dimensions)
+	}
+
+	stmts := []string{
+		`DROP TABLE IF EXISTS ` + ShadowKnowledgeTable,
+		`CREATE TABLE ` + ShadowKnowledgeTable + ` (LIKE ` + KnowledgeTable + ` INCLUDING DEFAULTS)`,
+		fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN embedding TYPE vector(%d)`, ShadowKnowledgeTable, dimensions),
+		`ALTER TABLE ` + ShadowKnowledgeTable + ` ADD CONSTRAINT ` + ShadowKnowledgeTable + `_pkey PRIMARY KEY (id)`,
+		`CREATE UNIQUE INDEX ` + ShadowKnowledgeTable + `_chunk_idx ON ` + ShadowKnowledgeTable + ` (produto_id, chunk_index)`,
+		`DELETE FROM embedding_indexes WHERE status = 'building'`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO embedding_indexes (model, dimensions, status)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (model, dimensions) DO UPDATE SET status = EXCLUDED.status, created_at = now(), activated_at = NULL
+	`, embeddingModel, dimensions, model.IndexBuilding)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SwitchOver promove a tabela sombra a índice ativo em uma única transação.
+// Estoque vem do índice atual e o flag active do catálogo, já que o job de
+// estoque e a desativação do crawler só atualizam a tabela ativa. O índice
+// anterior fica em RetiredKnowledgeTable até a próxima troca.
+//
+// Falhas na construção da sombra não entram na fila de falhas, então antes da
+// troca a cobertura é conferida contra o catálogo: sem force, produtos ativos
+// sem vetores na sombra ou ainda na fila de embeddings (cujos vetores na sombra
+// podem ser de um conteúdo antigo) impedem a troca (IncompleteShadowError).
+func (r *EmbeddingIndexRepository) SwitchOver(force bool) (model.EmbeddingIndex, error) {
+	building, ok, err := r.Building()
+	if err != nil {
+		return building, err
+	}
+	if !ok {
+		return building, ErrNoShadowIndex
+	}
+
+	ctx := context.Background()
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return building, err
+	}
+	defer tx.Rollback(ctx)
+
+	if !force {
+		missing, err := missingFromShadow(ctx, tx)
+		if err != nil {
+			return building, err
+		}
+		if len(missing) > 0 {
+			return building, &IncompleteShadowError{Missing: missing}
+		}
+	}
+
+	stmts := []string{
+		`UPDATE ` + ShadowKnowledgeTable + ` s SET stock = k.stock
+		FROM (SELECT DISTINCT ON (produto_id) produto_id, stock FROM ` + KnowledgeTable + ` ORDER BY produto_id, chunk_index) k
+		WHERE s.produto_id = k.produto_id`,
+		`UPDATE ` + ShadowKnowledgeTable + ` s SET active = COALESCE(
+			(SELECT r.active FROM product_raw_knowledge r WHERE r.produto_id = s.produto_id), false)`,
+
+		`DROP TABLE IF EXISTS ` + RetiredKnowledgeTable,
+		`ALTER TABLE ` + KnowledgeTable + ` RENAME TO ` + RetiredKnowledgeTable,
+		`ALTER TABLE ` + RetiredKnowledgeTable + ` RENAME CONSTRAINT ` + KnowledgeTable + `_pkey TO ` + RetiredKnowledgeTable + `_pkey`,
+		`ALTER INDEX IF EXISTS ` + KnowledgeTable + `_chunk_idx RENAME TO ` + RetiredKnowledgeTable + `_chunk_idx`,
+
+		`ALTER TABLE ` + ShadowKnowledgeTable + ` RENAME TO ` + KnowledgeTable,
+		`ALTER TABLE ` + KnowledgeTable + ` RENAME CONSTRAINT ` + ShadowKnowledgeTable + `_pkey TO ` + KnowledgeTable + `_pkey`,
+		`ALTER INDEX ` + ShadowKnowledgeTable + `_chunk_idx RENAME TO ` + KnowledgeTable + `_chunk_idx`,
+
+		`UPDATE embedding_indexes SET status = 'retired' WHERE status = 'active'`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return building, err
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE embedding_indexes SET status = $3, activated_at = now()
+		WHERE model = $1 AND dimensions = $2
+	`, building.Model, building.Dimensions, model.IndexActive)
+	if err != nil {
+		return building, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return building, err
+	}
+	building.Status = model.IndexActive
+	return building, nil
+}
+
+// missingFromShadow lista os produtos ativos com conteúdo que não têm nenhum
+// chunk na tabela sombra ou que aguardam novos embeddings.
+func missingFromShadow(ctx context.Context, tx pgx.Tx) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT r.produto_id
+		FROM product_raw_knowledge r
+		WHERE r.active AND COALESCE(r.raw_content, '') <> ''
+		  AND (r.sync_status = 'S' OR NOT EXISTS (SELECT 1 FROM `+ShadowKnowledgeTable+` s WHERE s.produto_id = r.produto_id))
+		ORDER BY r.produto_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		missing = append(missing, id)
+	}
+	return missing, rows.Err()
+}
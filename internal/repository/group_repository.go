@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"iaprj/internal/model"
+)
+
+// GroupRepository guarda os grupos de produtos duplicados (mesmo modelo em
+// vários IDs) calculados pelo job de dedup.
+type GroupRepository struct {
+	DB *pgxpool.Pool
+}
+
+// ListCandidates retorna os produtos ativos com o embedding médio de seus chunks.
+func (r *GroupRepository) ListCandidates() ([]model.DedupCandidate, error) {
+	rows, err := r.DB.Query(context.Background(), `
+		SELECT r.produto_id, split_part(COALESCE(r.raw_content, ''), E'\n', 1), COALESCE(r.brand, ''), r.btus,
+		       COALESCE(r.voltagem, ''), COALESCE(r.ciclo, ''), COALESCE(r.type, ''), r.sale_price,
+		       COALESCE(e.embedding::text, '')
+		FROM product_raw_knowledge r
+		LEFT JOIN (
+			SELECT produto_id, avg(embedding) AS embedding
+			FROM product_knowledge
+			WHERE active
+			GROUP BY produto_id
+		) e ON e.produto_id = r.produto_id
+		WHERE r.active
+		ORDER BY r.produto_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.DedupCandidate
+	for rows.Next() {
+		var c model.DedupCandidate
+		var emb string
+		if err := rows.Scan(&c.ProdutoID, &c.Name, &c.Brand, &c.Btus, &c.Voltagem, &c.Ciclo, &c.Type, &c.SalePrice, &emb); err != nil {
+			return nil, err
+		}
+		c.Embedding = parseVector(emb)
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// ReplaceGroups substitui todos os grupos em uma transação.
+func (r *GroupRepository) ReplaceGroups(members []model.ProductGroupMember) error {
+	ctx := context.Background()
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM product_groups`); err != nil {
+		return err
+	}
+	for _, m := range members {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO product_groups (produto_id, group_id, canonical, reason, updated_at)
+			VALUES ($1, $2, $3, $4, now())
+		`, m.ProdutoID, m.GroupID, m.Canonical, m.Reason)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// GetGroupMembers retorna os outros IDs do mesmo modelo (vazio se o produto não tem duplicados).
+func (r *GroupRepository) GetGroupMembers(produtoID string) ([]model.ProductGroupMember, error) {
+	rows, err := r.DB.Query(context.Background(), `
+		SELECT g.produto_id, g.group_id, g.canonical, g.reason
+		FROM product_groups g
+		JOIN product_groups self ON self.group_id = g.group_id
+		WHERE self.produto_id = $1 AND g.produto_id <> $1
+		ORDER BY g.canonical DESC, g.produto_id
+	`, produtoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []model.ProductGroupMember
+	for rows.Next() {
+		var m model.ProductGroupMember
+		if err := rows.Scan(&m.ProdutoID, &m.GroupID, &m.Canonical, &m.Reason); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// parseVector converte o texto do pgvector ("[0.1,0.2,...]") para []float32.
+func parseVector(s string) []float32 {
+	s = strings.Trim(s, "[]")
+	if s == "" {
+		return nil
+	}
+	parts := strings.Split(s, ",")
+	v := make([]float32, len(parts))
+	for i, p := range parts {
+		f, _ := strconv.ParseFloat(strings.TrimSpace(p), 32)
+		v[i] = float32(f)
+	}
+	return v
+}
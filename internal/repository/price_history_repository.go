@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"iaprj/internal/model"
+)
+
+// PriceHistoryRepository lê e grava o histórico de preços preenchido pelo crawler
+// e o histórico de disponibilidade preenchido pelo job de estoque.
+type PriceHistoryRepository struct {
+	DB *pgxpool.Pool
+}
+
+// RecordAvailability registra a disponibilidade verificada pelo job de estoque em
+// product_availability_history, uma linha por verificação.
+func (r *PriceHistoryRepository) RecordAvailability(produtoID string, inStock bool) error {
+	_, err := r.DB.Exec(context.Background(), `
+		INSERT INTO product_availability_history (produto_id, in_stock, source)
+		VALUES ($1, $2, 'stock')
+	`, produtoID, inStock)
+	return err
+}
+
+// GetPriceTrend compara o preço atual com o preço de days dias atrás e busca o
+// menor preço observado no período, em uma única consulta. Retorna nil se o
+// produto não tem histórico.
+func (r *PriceHistoryRepository) GetPriceTrend(produtoID string, days int) (*model.PriceTrend, error) {
+	t := &model.PriceTrend{ProdutoID: produtoID, Days: days}
+
+	// O preço anterior é o último antes do início da janela; sem ele, o primeiro
+	// observado dentro dela
+	var previousPrice, lowestPrice *float32
+	var previousAt, lowestAt *time.Time
+	err := r.DB.QueryRow(context.Background(), `
+		WITH h AS (
+			SELECT sale_price, observed_at FROM product_price_history
+			WHERE produto_id = $1 AND sale_price > 0
+		),
+		cur AS (
+			SELECT sale_price FROM h ORDER BY observed_at DESC LIMIT 1
+		),
+		prev AS (
+			(SELECT sale_price, observed_at FROM h
+			 WHERE observed_at <= now() - make_interval(days => $2)
+			 ORDER BY observed_at DESC LIMIT 1)
+			UNION ALL
+			(SELECT sale_price, observed_at FROM h
+			 WHERE observed_at > now() - make_interval(days => $2)
+			 ORDER BY observed_at ASC LIMIT 1)
+			LIMIT 1
+		),
+		low AS (
+			SELECT sale_price, observed_at FROM h
+			WHERE observed_at > now() - make_interval(days => $2)
+			ORDER BY sale_price ASC, observed_at DESC LIMIT 1
+		)
+		SELECT cur.sale_price, prev.sale_price, prev.observed_at, low.sale_price, low.observed_at
+		FROM cur
+		LEFT JOIN prev ON true
+		LEFT JOIN low ON true
+	`, produtoID, days).Scan(&t.CurrentPrice, &previousPrice, &previousAt, &lowestPrice, &lowestAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if previousPrice != nil {
+		t.PreviousPrice, t.PreviousAt = *previousPrice, *previousAt
+	}
+	if lowestPrice != nil {
+		t.LowestPrice, t.LowestAt = *lowestPrice, *lowestAt
+	}
+	return t, nil
+}
@@ -2,6 +2,8 @@ package repository
 
 import (
 	"database/sql"
+	"time"
+
 	"iaprj/internal/model"
 )
 
@@ -9,35 +11,339 @@ type RawRepository struct {
 	DB *sql.DB
 }
 
-func (r *RawRepository) Save(p model.RawProduct) error {
-	var exists bool
-	err := r.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM product_raw_knowledge WHERE produto_id = $1)", p.ProdutoID).Scan(&exists)
+// SaveStatus indica o que aconteceu com um produto ao ser salvo pelo crawler.
+type SaveStatus int
+
+const (
+	SaveNew SaveStatus = iota
+	SaveChanged
+	SaveUnchanged
+)
+
+// Save grava o produto bruto. O produto só volta para a fila de embeddings
+// (sync_status = 'S') quando o hash do conteúdo muda; caso contrário apenas os
+// metadados de preço e data de crawl são atualizados. O JSON original e as
+// colunas tipadas são sempre regravados. Tudo acontece em uma transação, para
+// que uma falha não deixe o conteúdo novo com as colunas tipadas antigas.
+func (r *RawRepository) Save(p model.RawProduct) (SaveStatus, error) {
+	tx, err := r.DB.Begin()
 	if err != nil {
-		return err
+		return SaveNew, err
 	}
+	defer tx.Rollback()
 
-	if exists {
-		_, err = r.DB.Exec(`
-			UPDATE product_raw_knowledge
-			SET source_url = $1, raw_content = $2, sync_status = 'S'
-			WHERE produto_id = $3
-		`, p.SourceURL, p.Content, p.ProdutoID)
-	} else {
-		_, err = r.DB.Exec(`
+	status, err := save(tx, p)
+	if err != nil {
+		return status, err
+	}
+	// Conteúdo novo merece novas tentativas mesmo que o anterior tenha esgotado as dele
+	if status != SaveUnchanged {
+		if _, err := tx.Exec(`DELETE FROM embedding_failures WHERE produto_id = $1`, p.ProdutoID); err != nil {
+			return status, err
+		}
+	}
+	if err := saveAttributes(tx, p); err != nil {
+		return status, err
+	}
+	if p.ListedCategoryID != "" {
+		_, err := tx.Exec(`
+			INSERT INTO product_categories (produto_id, category_id, category_path, seen_at)
+			VALUES ($1, $2, $3, now())
+			ON CONFLICT (produto_id, category_id) DO UPDATE SET category_path = EXCLUDED.category_path, seen_at = now()
+		`, p.ProdutoID, p.ListedCategoryID, p.ListedCategoryPath)
+		if err != nil {
+			return status, err
+		}
+	}
+	return status, tx.Commit()
+}
+
+// RecordPrice adiciona o preço visto no crawl a product_price_history.
+func (r *RawRepository) RecordPrice(p model.RawProduct) error {
+	if p.SalePrice <= 0 {
+		return nil
+	}
+	_, err := r.DB.Exec(`
+		INSERT INTO product_price_history (produto_id, sale_price, list_price, source)
+		VALUES ($1, $2, $3, 'crawler')
+	`, p.ProdutoID, p.SalePrice, p.ListPrice)
+	return err
+}
+
+func save(tx *sql.Tx, p model.RawProduct) (SaveStatus, error) {
+	if p.Source == "" {
+		p.Source = "occ"
+	}
+	if p.ContentHash == "" {
+		p.ContentHash = model.ContentHash(p.Content)
+	}
+
+	var currentHash, currentPath sql.NullString
+	var active bool
+	err := tx.QueryRow("SELECT content_hash, category_path, active FROM product_raw_knowledge WHERE produto_id = $1 FOR UPDATE", p.ProdutoID).Scan(&currentHash, &currentPath, &active)
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec(`
 			INSERT INTO product_raw_knowledge
-			(id, produto_id, source_url, raw_content, sync_status)
-			VALUES ($1, $2, $3, $4, 'S')
-		`, p.ID, p.ProdutoID, p.SourceURL, p.Content)
+			(id, produto_id, source_url, raw_content, sync_status, content_hash, last_modified, sale_price, list_price, crawled_at, source, category_id, category_path)
+			VALUES ($1, $2, $3, $4, 'S', $5, $6, $7, $8, now(), $9, NULLIF($10, ''), NULLIF($11, ''))
+		`, p.ID, p.ProdutoID, p.SourceURL, p.Content, p.ContentHash, p.LastModified, p.SalePrice, p.ListPrice, p.Source, p.CategoryID, p.CategoryPath)
+		return SaveNew, err
+	}
+	if err != nil {
+		return SaveNew, err
+	}
+
+	// Produto que havia sumido do catálogo e voltou: reativa também os vetores
+	if !active {
+		if _, err := tx.Exec(`UPDATE product_knowledge SET active = true WHERE produto_id = $1`, p.ProdutoID); err != nil {
+			return SaveNew, err
+		}
 	}
 
+	// A categoria também é gravada nos vetores, então mudar de categoria exige reprocessar
+	samePath := p.CategoryPath == "" || p.CategoryPath == currentPath.String
+	if currentHash.Valid && currentHash.String == p.ContentHash && samePath {
+		_, err = tx.Exec(`
+			UPDATE product_raw_knowledge
+			SET source_url = $1, last_modified = $2, sale_price = $3, list_price = $4, crawled_at = now(), source = $5,
+			    category_id = COALESCE(NULLIF($6, ''), category_id), active = true, inactive_since = NULL
+			WHERE produto_id = $7
+		`, p.SourceURL, p.LastModified, p.SalePrice, p.ListPrice, p.Source, p.CategoryID, p.ProdutoID)
+		return SaveUnchanged, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE product_raw_knowledge
+		SET source_url = $1, raw_content = $2, sync_status = 'S', content_hash = $3,
+		    last_modified = $4, sale_price = $5, list_price = $6, crawled_at = now(), source = $7,
+		    category_id = COALESCE(NULLIF($8, ''), category_id), category_path = COALESCE(NULLIF($9, ''), category_path),
+		    active = true, inactive_since = NULL
+		WHERE produto_id = $10
+	`, p.SourceURL, p.Content, p.ContentHash, p.LastModified, p.SalePrice, p.ListPrice, p.Source, p.CategoryID, p.CategoryPath, p.ProdutoID)
+	return SaveChanged, err
+}
+
+// saveAttributes grava o registro original e os campos estruturados derivados dele.
+func saveAttributes(tx *sql.Tx, p model.RawProduct) error {
+	var rawJSON interface{}
+	if len(p.RawJSON) > 0 {
+		rawJSON = string(p.RawJSON)
+	}
+	_, err := tx.Exec(`
+		UPDATE product_raw_knowledge
+		SET raw_json = COALESCE($1::jsonb, raw_json), image_url = $2, brand = $3, btus = $4, ciclo = $5,
+		    voltagem = $6, tecnologia = $7, type = $8, length = $9, weight = $10, width = $11, height = $12,
+		    text_template = COALESCE(NULLIF($13, ''), text_template),
+		    ruido_db = $14, procel = NULLIF($15, ''), gas = NULLIF($16, '')
+		WHERE produto_id = $17
+	`, rawJSON, p.ImageURL, p.Brand, p.Btus, p.Ciclo, p.Voltagem, p.Tecnologia, p.Type,
+		p.Length, p.Weight, p.Width, p.Height, p.TextTemplate, p.RuidoDB, p.Procel, p.Gas, p.ProdutoID)
 	return err
 }
 
-func (r *RawRepository) List() ([]model.RawProduct, error) {
+// DeactivateMissing trata os produtos da categoria que não foram vistos pelo
+// crawl iniciado em since: eles deixam de ser membros da categoria; os que
+// ainda estão em outra categoria passam a tê-la como principal, e os demais
+// são marcados como inativos, escondendo também seus vetores das buscas.
+func (r *RawRepository) DeactivateMissing(categoryID string, since time.Time) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM product_categories WHERE category_id = $1 AND seen_at < $2`, categoryID, since)
+	if err != nil {
+		return 0, err
+	}
+
+	// A categoria também é gravada nos vetores, então a troca exige reprocessar
+	_, err = tx.Exec(`
+		UPDATE product_raw_knowledge r
+		SET category_id = c.category_id, category_path = NULLIF(c.category_path, ''), sync_status = 'S'
+		FROM (
+			SELECT DISTINCT ON (produto_id) produto_id, category_id, category_path
+			FROM product_categories
+			ORDER BY produto_id, seen_at DESC
+		) c
+		WHERE c.produto_id = r.produto_id AND r.category_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM product_categories m WHERE m.produto_id = r.produto_id AND m.category_id = $1)
+	`, categoryID)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE product_raw_knowledge
+		SET active = false, inactive_since = now()
+		WHERE category_id = $1 AND active AND (crawled_at IS NULL OR crawled_at < $2)
+	`, categoryID, since)
+	if err != nil {
+		return 0, err
+	}
+	count, _ := res.RowsAffected()
+
+	_, err = tx.Exec(`
+		UPDATE product_knowledge pk
+		SET active = false
+		FROM product_raw_knowledge r
+		WHERE r.produto_id = pk.produto_id AND NOT r.active AND pk.active
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+// PurgeInactive apaga definitivamente os produtos inativos há mais que grace,
+// junto com seus chunks de vetor.
+func (r *RawRepository) PurgeInactive(grace time.Duration) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		DELETE FROM product_knowledge
+		WHERE produto_id IN (
+			SELECT produto_id FROM product_raw_knowledge
+			WHERE NOT active AND inactive_since < now() - make_interval(secs => $1)
+		)
+	`, grace.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM product_categories
+		WHERE produto_id IN (
+			SELECT produto_id FROM product_raw_knowledge
+			WHERE NOT active AND inactive_since < now() - make_interval(secs => $1)
+		)
+	`, grace.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		DELETE FROM product_raw_knowledge
+		WHERE NOT active AND inactive_since < now() - make_interval(secs => $1)
+	`, grace.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	count, _ := res.RowsAffected()
+
+	return count, tx.Commit()
+}
+
+// List retorna os produtos pendentes de embedding com suas colunas tipadas,
+// exceto os que já falharam maxAttempts vezes.
+func (r *RawRepository) List(maxAttempts int) ([]model.RawProduct, error) {
+	return r.listForEmbedding(`sync_status = 'S' AND active AND produto_id NOT IN (
+		SELECT produto_id FROM embedding_failures WHERE attempts >= $1
+	)`, maxAttempts)
+}
+
+// ListIndexable retorna todos os produtos ativos, pendentes ou não, para a
+// construção de um novo índice de embeddings.
+func (r *RawRepository) ListIndexable() ([]model.RawProduct, error) {
+	return r.listForEmbedding(`active`)
+}
+
+func (r *RawRepository) listForEmbedding(where string, args ...interface{}) ([]model.RawProduct, error) {
 	rows, err := r.DB.Query(`
-		SELECT id, produto_id, source_url, raw_content
+		SELECT id, produto_id, COALESCE(source_url, ''), COALESCE(raw_content, ''), COALESCE(category_path, ''),
+		       COALESCE(image_url, ''), COALESCE(brand, ''), btus, COALESCE(ciclo, ''), COALESCE(voltagem, ''),
+		       COALESCE(tecnologia, ''), COALESCE(type, ''), sale_price, length, weight, width, height
 		FROM product_raw_knowledge
-		WHERE sync_status = 'S'
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.RawProduct
+	for rows.Next() {
+		var p model.RawProduct
+		err := rows.Scan(&p.ID, &p.ProdutoID, &p.SourceURL, &p.Content, &p.CategoryPath,
+			&p.ImageURL, &p.Brand, &p.Btus, &p.Ciclo, &p.Voltagem,
+			&p.Tecnologia, &p.Type, &p.SalePrice, &p.Length, &p.Weight, &p.Width, &p.Height)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+
+	return list, rows.Err()
+}
+
+// ListActive retorna os campos comparados pelo modo -dry-run do crawler para
+// todos os produtos ativos.
+func (r *RawRepository) ListActive() ([]model.RawProduct, error) {
+	rows, err := r.DB.Query(`
+		SELECT produto_id, COALESCE(category_id, ''), COALESCE(source_url, ''), COALESCE(image_url, ''),
+		       COALESCE(brand, ''), btus, COALESCE(ciclo, ''), COALESCE(voltagem, ''), sale_price,
+		       COALESCE(content_hash, ''), COALESCE(category_path, '')
+		FROM product_raw_knowledge
+		WHERE active
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.RawProduct
+	for rows.Next() {
+		var p model.RawProduct
+		err := rows.Scan(&p.ProdutoID, &p.CategoryID, &p.SourceURL, &p.ImageURL,
+			&p.Brand, &p.Btus, &p.Ciclo, &p.Voltagem, &p.SalePrice, &p.ContentHash, &p.CategoryPath)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+
+	return list, rows.Err()
+}
+
+// PrimaryCategory escolhe a categoria principal de um produto encontrado em
+// categoryID: a já gravada continua enquanto o produto ainda estiver listado
+// nela, para que produtos em várias categorias não troquem de departamento (e
+// voltem para a fila de embeddings) a cada crawl.
+func (r *RawRepository) PrimaryCategory(produtoID, categoryID, categoryPath string) (string, string, error) {
+	var storedID, storedPath sql.NullString
+	var listed bool
+	err := r.DB.QueryRow(`
+		SELECT r.category_id, r.category_path,
+		       EXISTS (SELECT 1 FROM product_categories c WHERE c.produto_id = r.produto_id AND c.category_id = r.category_id)
+		FROM product_raw_knowledge r
+		WHERE r.produto_id = $1
+	`, produtoID).Scan(&storedID, &storedPath, &listed)
+	if err == sql.ErrNoRows {
+		return categoryID, categoryPath, nil
+	}
+	if err != nil {
+		return categoryID, categoryPath, err
+	}
+	if storedID.String == "" || storedID.String == categoryID || !listed {
+		return categoryID, categoryPath, nil
+	}
+	return storedID.String, storedPath.String, nil
+}
+
+// ListStored retorna os produtos ativos com o JSON original guardado, usado pelo
+// reprocessamento sem novo crawl. Produtos inativos ficam de fora para que o
+// reprocessamento não os reative.
+func (r *RawRepository) ListStored() ([]model.RawProduct, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, produto_id, source, COALESCE(source_url, ''), COALESCE(category_id, ''),
+		       COALESCE(category_path, ''), raw_json::text
+		FROM product_raw_knowledge
+		WHERE raw_json IS NOT NULL AND active
+		ORDER BY produto_id
 	`)
 	if err != nil {
 		return nil, err
@@ -47,18 +353,143 @@ func (r *RawRepository) List() ([]model.RawProduct, error) {
 	var list []model.RawProduct
 	for rows.Next() {
 		var p model.RawProduct
-		rows.Scan(&p.ID, &p.ProdutoID, &p.SourceURL, &p.Content)
+		var raw string
+		if err := rows.Scan(&p.ID, &p.ProdutoID, &p.Source, &p.SourceURL, &p.CategoryID, &p.CategoryPath, &raw); err != nil {
+			return nil, err
+		}
+		p.RawJSON = []byte(raw)
 		list = append(list, p)
 	}
 
-	return list, nil
+	return list, rows.Err()
 }
 
+// RequeueEmbedding devolve o produto à fila de embeddings registrando a falha,
+// usado quando ele entrou no índice ativo mas não no índice sombra.
+func (r *RawRepository) RequeueEmbedding(produtoID, reason, message string) error {
+	if err := r.RecordEmbeddingFailure(produtoID, reason, message); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec(`UPDATE product_raw_knowledge SET sync_status = 'S' WHERE produto_id = $1`, produtoID)
+	return err
+}
+
+// MarkAsProcessed tira o produto da fila de embeddings e da fila de falhas.
 func (r *RawRepository) MarkAsProcessed(produtoID string) error {
 	_, err := r.DB.Exec(`
 		UPDATE product_raw_knowledge
 		SET sync_status = 'N'
 		WHERE produto_id = $1
 	`, produtoID)
+	if err != nil {
+		return err
+	}
+	_, err = r.DB.Exec(`DELETE FROM embedding_failures WHERE produto_id = $1`, produtoID)
 	return err
 }
+
+// RecordEmbeddingFailure registra (ou incrementa) a falha de embedding do
+// produto. O produto continua com sync_status = 'S'.
+func (r *RawRepository) RecordEmbeddingFailure(produtoID, reason, message string) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO embedding_failures (produto_id, reason, error, attempts, first_failed_at, last_attempt_at)
+		VALUES ($1, $2, $3, 1, now(), now())
+		ON CONFLICT (produto_id) DO UPDATE SET
+			reason          = EXCLUDED.reason,
+			error           = EXCLUDED.error,
+			attempts        = embedding_failures.attempts + 1,
+			last_attempt_at = now()
+	`, produtoID, reason, message)
+	return err
+}
+
+// ListFailed retorna os produtos da fila de falhas com menos de maxAttempts tentativas.
+func (r *RawRepository) ListFailed(maxAttempts int) ([]model.RawProduct, error) {
+	return r.listForEmbedding(`active AND produto_id IN (
+		SELECT produto_id FROM embedding_failures WHERE attempts < $1
+	)`, maxAttempts)
+}
+
+// ResetEmbeddingFailures remove o produto da fila de falhas, zerando suas
+// tentativas; produtoID vazio limpa a fila inteira.
+func (r *RawRepository) ResetEmbeddingFailures(produtoID string) (int64, error) {
+	res, err := r.DB.Exec(`DELETE FROM embedding_failures WHERE $1 = '' OR produto_id = $1`, produtoID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListExhausted retorna as falhas que atingiram maxAttempts e precisam de
+// inspeção manual; elas não são mais processadas automaticamente.
+func (r *RawRepository) ListExhausted(maxAttempts int) ([]model.EmbeddingFailure, error) {
+	rows, err := r.DB.Query(`
+		SELECT produto_id, reason, COALESCE(error, ''), attempts, first_failed_at, last_attempt_at
+		FROM embedding_failures
+		WHERE attempts >= $1
+		ORDER BY last_attempt_at DESC
+	`, maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.EmbeddingFailure
+	for rows.Next() {
+		var f model.EmbeddingFailure
+		if err := rows.Scan(&f.ProdutoID, &f.Reason, &f.Error, &f.Attempts, &f.FirstFailedAt, &f.LastAttemptAt); err != nil {
+			return nil, err
+		}
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+// SaveComponents substitui a composição do kit.
+func (r *RawRepository) SaveComponents(produtoID string, components []model.ProductComponent) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM product_components WHERE produto_id = $1`, produtoID); err != nil {
+		return err
+	}
+	for _, c := range components {
+		_, err := tx.Exec(`
+			INSERT INTO product_components
+			(produto_id, component_sku, component_produto_id, name, role, quantity, length, weight, width, height, sale_price)
+			VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, $11)
+		`, produtoID, c.ComponentSKU, c.ComponentProdutoID, c.Name, c.Role, c.Quantity,
+			c.Length, c.Weight, c.Width, c.Height, c.SalePrice)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SaveVariants substitui as variantes conhecidas do produto.
+func (r *RawRepository) SaveVariants(produtoID string, variants []model.ProductVariant) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM product_variants WHERE produto_id = $1`, produtoID); err != nil {
+		return err
+	}
+	for _, v := range variants {
+		_, err := tx.Exec(`
+			INSERT INTO product_variants (produto_id, variant_produto_id, label, attribute)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (produto_id, variant_produto_id) DO UPDATE SET label = EXCLUDED.label, attribute = EXCLUDED.attribute
+		`, produtoID, v.VariantProdutoID, v.Label, v.Attribute)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
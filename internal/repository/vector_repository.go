@@ -11,6 +11,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"iaprj/internal/model"
 )
 
 type VectorResult struct {
@@ -35,30 +37,89 @@ type VectorResult struct {
 
 type VectorRepository struct {
 	DB *pgxpool.Pool
+	// Table é a tabela onde ReplaceChunks grava; vazia usa KnowledgeTable.
+	// As buscas sempre leem o índice ativo.
+	Table string
 }
 
-func (r *VectorRepository) Save(
-	produtoID, sourceURL, imageURL, brand string, btus int, ciclo string, voltagem string, tecnologia string, productType string, content string,
-	embedding []float32, salesPrice float32, length float32, weight float32, width float32, height float32,
-) error {
+func (r *VectorRepository) table() string {
+	if r.Table == "" {
+		return KnowledgeTable
+	}
+	return r.Table
+}
 
-	// converte []float32 para "[v1,v2,...]" (pgvector espera colchetes)
-	parts := make([]string, len(embedding))
-	for i, v := range embedding {
-		parts[i] = strconv.FormatFloat(float64(v), 'f', -1, 64)
+// ProductChunk é um trecho do documento de um produto com o seu embedding.
+type ProductChunk struct {
+	Index     int
+	Content   string
+	Embedding []float32
+}
+
+// ReplaceChunks grava o conjunto de chunks de um produto em uma transação,
+// junto com o modelo que gerou os vetores. Cada chunk é identificado por
+// (produto_id, chunk_index): reprocessar um produto atualiza as linhas
+// existentes e remove as que sobraram de uma versão anterior com mais chunks,
+// em vez de acumular vetores duplicados.
+func (r *VectorRepository) ReplaceChunks(p model.RawProduct, embeddingModel string, chunks []ProductChunk) error {
+	ctx := context.Background()
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
 	}
-	embStr := "[" + strings.Join(parts, ",") + "]"
+	defer tx.Rollback(ctx)
 
-	// Remove sequências de bytes inválidas para evitar erro "invalid byte sequence for encoding UTF8"
-	validContent := strings.ToValidUTF8(content, "")
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM `+r.table()+` WHERE produto_id = $1 AND chunk_index >= $2
+	`, p.ProdutoID, len(chunks)); err != nil {
+		return err
+	}
 
-	_, err := r.DB.Exec(context.Background(), `
-		INSERT INTO product_knowledge
-		(id, produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content, embedding, sale_price, length, weight, width, height)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-	`, uuid.New(), produtoID, sourceURL, imageURL, brand, btus, ciclo, voltagem, tecnologia, productType, validContent, embStr, salesPrice, length, weight, width, height)
+	for _, c := range chunks {
+		// Remove sequências de bytes inválidas para evitar erro "invalid byte sequence for encoding UTF8"
+		content := strings.ToValidUTF8(c.Content, "")
+
+		_, err := tx.Exec(ctx, `
+			INSERT INTO `+r.table()+`
+			(id, produto_id, chunk_index, content_hash, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content, embedding, sale_price, length, weight, width, height, category_path, embedding_model)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+			ON CONFLICT (produto_id, chunk_index) DO UPDATE SET
+				content_hash  = EXCLUDED.content_hash,
+				source_url    = EXCLUDED.source_url,
+				image_url     = EXCLUDED.image_url,
+				brand         = EXCLUDED.brand,
+				btus          = EXCLUDED.btus,
+				ciclo         = EXCLUDED.ciclo,
+				voltagem      = EXCLUDED.voltagem,
+				tecnologia    = EXCLUDED.tecnologia,
+				type          = EXCLUDED.type,
+				content       = EXCLUDED.content,
+				embedding     = EXCLUDED.embedding,
+				sale_price    = EXCLUDED.sale_price,
+				length        = EXCLUDED.length,
+				weight        = EXCLUDED.weight,
+				width         = EXCLUDED.width,
+				height        = EXCLUDED.height,
+				category_path = EXCLUDED.category_path,
+				active        = true,
+				embedding_model = EXCLUDED.embedding_model
+		`, uuid.New(), p.ProdutoID, c.Index, model.ContentHash(content), p.SourceURL, p.ImageURL, p.Brand, p.Btus, p.Ciclo, p.Voltagem, p.Tecnologia, p.Type,
+			content, formatVector(c.Embedding), p.SalePrice, p.Length, p.Weight, p.Width, p.Height, p.CategoryPath, embeddingModel)
+		if err != nil {
+			return err
+		}
+	}
 
-	return err
+	return tx.Commit(ctx)
+}
+
+// formatVector converte []float32 para "[v1,v2,...]" (pgvector espera colchetes)
+func formatVector(embedding []float32) string {
+	parts := make([]string, len(embedding))
+	for i, v := range embedding {
+		parts[i] = strconv.FormatFloat(float64(v), 'f', -1, 64)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
 }
 
 func (r *VectorRepository) SearchSimilar(
@@ -124,15 +185,22 @@ func (r *VectorRepository) SearchSimilar(
 
 	orderByClause := fmt.Sprintf("(embedding <=> $1) * (%s)", caseBuilder.String())
 
+	// Produtos do mesmo grupo de duplicados (product_groups) aparecem uma única vez,
+	// representados pelo anúncio com melhor resultado
 	query := fmt.Sprintf(`
 		SELECT produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content, score, sale_price, length, weight, width, height, stock
 		FROM (
-			SELECT DISTINCT ON (produto_id) produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content,
-			       1 - (embedding <=> $1) AS score, sale_price, length, weight, width, height, stock,
-			       %s AS sort_val
-			FROM product_knowledge		
-			WHERE stock = 1 AND %s
-			ORDER BY produto_id, sort_val ASC
+			SELECT DISTINCT ON (group_key) produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content,
+			       score, sale_price, length, weight, width, height, stock, sort_val
+			FROM (
+				SELECT produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content,
+				       1 - (embedding <=> $1) AS score, sale_price, length, weight, width, height, stock,
+				       %s AS sort_val,
+				       COALESCE((SELECT g.group_id FROM product_groups g WHERE g.produto_id = product_knowledge.produto_id), produto_id) AS group_key
+				FROM product_knowledge
+				WHERE stock = 1 AND active AND %s
+			) chunks
+			ORDER BY group_key, sort_val ASC
 		) sub
 		ORDER BY sort_val ASC
 		LIMIT $3
@@ -208,7 +276,7 @@ func (r *VectorRepository) SearchByBrand(
 		SELECT produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content, 
 		       1 - (embedding <=> $1) AS score,sale_price, length, weight, width, height, stock
 		FROM product_knowledge
-		WHERE  stock = 1 AND  %s
+		WHERE stock = 1 AND active AND %s
 		ORDER BY embedding <=> $1 ASC
 		LIMIT $3
 	`, whereClause)
@@ -291,7 +359,7 @@ func (r *VectorRepository) SearchByMetadata(
 			FROM (
 				SELECT DISTINCT ON (produto_id) produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content, 0 AS score, sale_price, length, weight, width, height, stock
 				FROM product_knowledge
-				WHERE  stock = 1 AND  %s
+				WHERE stock = 1 AND active AND %s
 				ORDER BY produto_id, btus ASC
 			) sub
 			ORDER BY btus ASC
@@ -340,7 +408,7 @@ func (r *VectorRepository) SearchByMetadata(
 
 // GetChunksByProductID retrieves all content chunks for a given product ID, ordered to reconstruct the document.
 func (r *VectorRepository) GetChunksByProductID(productID string) ([]string, error) {
-	query := `SELECT content FROM product_knowledge WHERE produto_id = $1 ORDER BY created_at ASC`
+	query := `SELECT content FROM product_knowledge WHERE produto_id = $1 ORDER BY chunk_index ASC`
 	rows, err := r.DB.Query(context.Background(), query, productID)
 	if err != nil {
 		return nil, err
@@ -357,9 +425,41 @@ func (r *VectorRepository) GetChunksByProductID(productID string) ([]string, err
 	return contents, nil
 }
 
-// GetAllProductsForUpdate retrieves minimal product data required to check stock availability.
+// GetProductsByIDs retrieves the structured data of the given products, one row per product,
+// keeping the order of ids.
+func (r *VectorRepository) GetProductsByIDs(ids []string) ([]VectorResult, error) {
+	rows, err := r.DB.Query(context.Background(), `
+		SELECT DISTINCT ON (produto_id) produto_id, source_url, image_url, brand, btus, ciclo, voltagem, tecnologia, type, content, 0 AS score, sale_price, length, weight, width, height, stock
+		FROM product_knowledge
+		WHERE produto_id = ANY($1) AND active
+		ORDER BY produto_id, chunk_index ASC
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[string]VectorResult)
+	for rows.Next() {
+		var r VectorResult
+		if err := rows.Scan(&r.ProdutoID, &r.SourceURL, &r.ImageURL, &r.Brand, &r.Btus, &r.Ciclo, &r.Voltagem, &r.Tecnologia, &r.Type, &r.Content, &r.Score, &r.SalePrice, &r.Length, &r.Weight, &r.Width, &r.Height, &r.Stock); err == nil {
+			byID[r.ProdutoID] = r
+		}
+	}
+
+	var results []VectorResult
+	for _, id := range ids {
+		if r, ok := byID[id]; ok {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// GetAllProductsForUpdate retrieves minimal product data required to check stock availability,
+// including the current stock so the caller can detect availability changes.
 func (r *VectorRepository) GetAllProductsForUpdate() ([]VectorResult, error) {
-	query := `SELECT distinct produto_id FROM product_knowledge`
+	query := `SELECT produto_id, MAX(stock) FROM product_knowledge WHERE active GROUP BY produto_id`
 	rows, err := r.DB.Query(context.Background(), query)
 	if err != nil {
 		return nil, err
@@ -369,7 +469,7 @@ func (r *VectorRepository) GetAllProductsForUpdate() ([]VectorResult, error) {
 	var results []VectorResult
 	for rows.Next() {
 		var vr VectorResult
-		if err := rows.Scan(&vr.ProdutoID); err == nil {
+		if err := rows.Scan(&vr.ProdutoID, &vr.Stock); err == nil {
 			results = append(results, vr)
 		}
 	}